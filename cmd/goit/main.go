@@ -3,14 +3,20 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"projet-go-git/internal/branch"
 	"projet-go-git/internal/checkout"
+	"projet-go-git/internal/config"
 	"projet-go-git/internal/index"
 	"projet-go-git/internal/log"
 	"projet-go-git/internal/merge"
+	"projet-go-git/internal/migrate"
+	"projet-go-git/internal/repack"
 	"projet-go-git/internal/repository"
+	"projet-go-git/internal/reset"
 	"projet-go-git/internal/status"
+	"projet-go-git/internal/tag"
 )
 
 func printHelp() {
@@ -21,32 +27,131 @@ Commands:
 	init                   Initialize a new goit repository (.goit/)
 	add <file>             Add a file to the staging area
 	commit -m <message>    Commit the staged changes with a message
-	log                    Show detailed commit history
+	log [<rev>|<a>..<b>|<a>...<b>]
+	                       Show detailed commit history
 	log --compact          Show commit history compact
+	log -p|--stat [<rev>]  Show the history with the changes of each commit (patch or diffstat)
+	show [<rev>] [--stat]  Show a commit and its changes (HEAD by default)
 	status                 Show changes in the working directory
 	branch                 List branches
-	branch <name>          Create a new branch
+	branch <name> [<rev>]  Create a new branch (at HEAD by default)
 	checkout <name>        Switch to a branch
-	diff <file>            Show differences between working directory and index
-	merge <branch>         Merge a branch into the current branch
-	resolve                Finalize merge after resolving conflicts
+	checkout <commit|tag>  Detach HEAD at a commit (full or short hash) or a tag
+	checkout [<rev>] -- <paths...>
+	                       Restore files from the index (or from <rev>)
+	restore [--source <rev>] [--staged] [--worktree] <paths...>
+	                       Restore files in the working tree and/or the index
+	reset [--soft|--mixed|--hard] [<rev>]
+	                       Move the current branch to <rev> (mixed: also the index, hard: also the files)
+	reset --merge [<rev>]  Like --hard, but keep unstaged changes of files the reset does not touch
+	reset [<rev>] [--] <paths...>
+	                       Unstage files (restore their index entry from HEAD or <rev>)
+	tag                    List tags
+	tag <name> [<commit>]  Create a tag (at HEAD by default)
+	diff [<paths>...]      Show changes between the index and the working directory (unified diff)
+	diff --staged [<rev>] [-- <paths>...]
+	                       Show changes between HEAD (or <rev>) and the index
+	diff <rev> [-- <paths>...]
+	                       Show changes between a commit and the working directory
+	diff <rev1> <rev2> [-- <paths>...]
+	                       Show changes between two commits (also <rev1>..<rev2>, <rev1>...<rev2>)
+	diff --stat|--numstat|--shortstat [...]
+	                       Show a summary of the changes instead of the patch
+	diff --diff-algorithm=<name> [...]
+	                       Choose the diff algorithm: myers (default), minimal, patience or histogram
+	                       (also for log and show, or with the diff.algorithm config key)
+	diff --word-diff[=plain|color|porcelain] [--word-diff-regex=<regex>] [...]
+	                       Compare changed lines word by word (also for log and show;
+	                       --color-words[=<regex>] is --word-diff=color, --word-diff-regex=. compares characters,
+	                       the default regex can be set with the diff.wordRegex config key)
+	merge <branch|rev>     Merge a branch (or any revision) into the current branch
+	merge --ff-only <rev>  Merge only if it is a fast-forward
+	merge --no-ff <rev>    Always create a merge commit
+	merge --abort          Abort a conflicted merge and restore the pre-merge state
+	merge-base <a> <b>     Show the best common ancestor of two commits
+	merge-base --all <a> <b>
+	                       Show all best common ancestors (criss-cross merges)
+	resolve [--force]      Finalize merge after resolving conflicts
+	                       (--force: commit files that still contain conflict markers)
+	migrate merge-commits  Re-hash merge commits written by older versions
+	migrate snapshots      Rebuild full snapshots from partial commits
+	migrate objects        Convert objects without header to typed objects
+	repack                 Pack loose objects into a packfile with delta compression
+	config [--global] <key> [<value>]
+	                       Get or set a configuration value (e.g. user.name)
 	help                   Show this help message
 
+Revisions:
+	HEAD, <branch>, <tag>, <hash> or a unique short hash (4+ characters)
+	<rev>~<n>              n-th first-parent ancestor (HEAD~3)
+	<rev>^<n>              n-th parent (main^2 is the merged side of a merge)
+	<branch>@{<n>}         n-th previous value of a branch (reflog)
+
 Examples:
 	goit init
 	goit add fichier.txt
 	goit commit -m "Initial commit"
 	goit log
 	goit log --compact
+	goit log main..feature-1
 	goit status
 	goit branch feature-1
 	goit checkout feature-1
+	goit tag v1.0
+	goit checkout v1.0
+	goit checkout HEAD~2
+	goit restore fichier.txt
+	goit restore --staged fichier.txt
+	goit restore --source HEAD~1 src/
+	goit reset --hard HEAD~1
+	goit reset fichier.txt
 	goit diff fichier.txt
+	goit diff --stat main..feature-1
+	goit show HEAD~1
+	goit diff --diff-algorithm=patience main.go
+	goit diff --word-diff README.md
 	goit merge feature-1
+	goit merge-base main feature-1
 	goit resolve
+	goit repack
+	goit config user.name "Jane Doe"
 `)
 }
 
+/**
+ * Reconnaît les options de sortie communes à diff, log et show (format, algorithme, diff par mots)
+ * Retourne false si arg n'en est pas une
+ */
+func parseDiffOption(arg string, opts *status.DiffOptions) bool {
+	switch {
+	case arg == "--stat":
+		opts.Format = status.DiffStat
+	case arg == "--numstat":
+		opts.Format = status.DiffNumstat
+	case arg == "--shortstat":
+		opts.Format = status.DiffShortstat
+	case strings.HasPrefix(arg, "--diff-algorithm="):
+		opts.Algorithm = strings.TrimPrefix(arg, "--diff-algorithm=")
+	case arg == "--minimal" || arg == "--patience" || arg == "--histogram":
+		opts.Algorithm = strings.TrimPrefix(arg, "--")
+	case arg == "--word-diff":
+		opts.WordDiff = status.WordDiffPlain
+	case strings.HasPrefix(arg, "--word-diff="):
+		opts.WordDiff = strings.TrimPrefix(arg, "--word-diff=")
+	case strings.HasPrefix(arg, "--word-diff-regex="):
+		opts.WordRegex = strings.TrimPrefix(arg, "--word-diff-regex=")
+		if opts.WordDiff == "" {
+			opts.WordDiff = status.WordDiffPlain
+		}
+	case arg == "--color-words" || strings.HasPrefix(arg, "--color-words="):
+		opts.WordDiff = status.WordDiffColor
+		opts.WordRegex = strings.TrimPrefix(strings.TrimPrefix(arg, "--color-words"), "=")
+	default:
+		return false
+	}
+	return true
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		printHelp()
@@ -54,7 +159,7 @@ func main() {
 	}
 
 	// Vérifie qu'un repository est existant pour les commandes dans la liste.
-	needsRepo := []string{"add", "commit", "log", "status", "branch", "checkout", "diff", "merge"}
+	needsRepo := []string{"add", "commit", "log", "show", "status", "branch", "checkout", "restore", "reset", "tag", "diff", "merge", "merge-base", "migrate", "repack"}
 	cmd := os.Args[1]
 
 	for _, needRepo := range needsRepo {
@@ -83,48 +188,329 @@ func main() {
 		}
 		repository.Commit(os.Args[3])
 	case "log":
-		if len(os.Args) > 2 && (os.Args[2] == "--compact" || os.Args[2] == "-c") {
-			log.ShowLogShort()
+		compact, patch := false, false
+		var rev string
+		var diffOpts status.DiffOptions
+		for _, arg := range os.Args[2:] {
+			switch {
+			case arg == "--compact" || arg == "-c":
+				compact = true
+			case arg == "-p" || arg == "--patch":
+				patch = true
+			case parseDiffOption(arg, &diffOpts):
+			default:
+				rev = arg
+			}
+		}
+		// Les options d'algorithme seules n'affichent pas de diff, comme avec git log
+		var showDiff *status.DiffOptions
+		if patch || diffOpts.Format != "" || diffOpts.WordDiff != "" {
+			showDiff = &diffOpts
+		}
+		if compact {
+			log.ShowLogShort(rev, showDiff)
 		} else {
-			log.ShowLog()
+			log.ShowLog(rev, showDiff)
 		}
+	case "show":
+		var rev string
+		var diffOpts status.DiffOptions
+		for _, arg := range os.Args[2:] {
+			if !parseDiffOption(arg, &diffOpts) {
+				rev = arg
+			}
+		}
+		log.ShowCommit(rev, diffOpts)
 	case "status":
 		status.ShowStatus()
 	case "branch":
 		if len(os.Args) < 3 {
 			branch.List()
+		} else if len(os.Args) == 3 {
+			branch.Create(os.Args[2], "")
 		} else {
-			branch.Create(os.Args[2])
+			branch.Create(os.Args[2], os.Args[3])
 		}
 	case "checkout":
+		// goit checkout [<rev>] -- <paths> : restaure des fichiers sans changer de branche
+		for i, arg := range os.Args[2:] {
+			if arg != "--" {
+				continue
+			}
+			paths := os.Args[i+3:]
+			if len(paths) == 0 {
+				fmt.Println("Usage: goit checkout [<rev>] -- <paths...>")
+				return
+			}
+			var err error
+			if i == 0 {
+				err = checkout.Restore(paths, "", false, true)
+			} else {
+				err = checkout.Restore(paths, os.Args[2], true, true)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return
+		}
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: goit checkout <branch>")
+			fmt.Println("Usage: goit checkout <branch|commit|tag>")
 			return
 		}
 		if err := checkout.Checkout(os.Args[2]); err != nil {
 			fmt.Printf("Error: %v\n", err)
 		}
+	case "tag":
+		switch len(os.Args) {
+		case 2:
+			tag.List()
+		case 3:
+			tag.Create(os.Args[2], "")
+		default:
+			tag.Create(os.Args[2], os.Args[3])
+		}
+	case "restore":
+		var source string
+		var paths []string
+		staged, worktree := false, false
+		args := os.Args[2:]
+		for i := 0; i < len(args); i++ {
+			switch arg := args[i]; {
+			case arg == "--staged" || arg == "-S":
+				staged = true
+			case arg == "--worktree" || arg == "-W":
+				worktree = true
+			case (arg == "--source" || arg == "-s") && i+1 < len(args):
+				i++
+				source = args[i]
+			case strings.HasPrefix(arg, "--source="):
+				source = strings.TrimPrefix(arg, "--source=")
+			case arg == "--":
+				paths = append(paths, args[i+1:]...)
+				i = len(args)
+			default:
+				paths = append(paths, arg)
+			}
+		}
+		if len(paths) == 0 {
+			fmt.Println("Usage: goit restore [--source <rev>] [--staged] [--worktree] <paths...>")
+			return
+		}
+		if err := checkout.Restore(paths, source, staged, worktree); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "reset":
+		mode := ""
+		var args, paths []string
+		hasSeparator := false
+		for i, arg := range os.Args[2:] {
+			switch arg {
+			case "--soft":
+				mode = reset.ModeSoft
+			case "--mixed":
+				mode = reset.ModeMixed
+			case "--hard":
+				mode = reset.ModeHard
+			case "--merge":
+				mode = reset.ModeMerge
+			case "--":
+				hasSeparator = true
+				paths = os.Args[i+3:]
+			default:
+				args = append(args, arg)
+				continue
+			}
+			if hasSeparator {
+				break
+			}
+		}
+
+		// goit reset [<rev>] [--] <paths> : retire des fichiers de l'index
+		// Sans "--", le premier argument est la révision s'il en désigne une, sinon un chemin
+		if !hasSeparator && mode == "" && len(args) > 0 {
+			if _, err := repository.ResolveRevision(args[0]); err != nil {
+				paths, args = args, nil
+			} else if len(args) > 1 {
+				paths, args = args[1:], args[:1]
+			}
+		}
+		var err error
+		switch {
+		case len(paths) > 0:
+			if mode != "" {
+				fmt.Println("Cannot reset paths with --soft, --mixed, --hard or --merge")
+				return
+			}
+			rev := ""
+			if len(args) > 0 {
+				rev = args[0]
+			}
+			err = reset.ResetPaths(rev, paths)
+		case len(args) > 1:
+			fmt.Println("Usage: goit reset [--soft|--mixed|--hard|--merge] [<rev>] | goit reset [<rev>] [--] <paths...>")
+			return
+		default:
+			if mode == "" {
+				mode = reset.ModeMixed
+			}
+			rev := ""
+			if len(args) == 1 {
+				rev = args[0]
+			}
+			err = reset.Reset(mode, rev)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
 	case "diff":
-		var filename string
-		if len(os.Args) >= 3 {
-			filename = os.Args[2]
+		var opts status.DiffOptions
+		var args []string
+		hasSeparator := false
+		for i, arg := range os.Args[2:] {
+			if arg == "--" {
+				hasSeparator = true
+				opts.Paths = os.Args[i+3:]
+				break
+			}
+			switch arg {
+			case "--staged", "--cached":
+				opts.Staged = true
+			default:
+				if !parseDiffOption(arg, &opts) {
+					args = append(args, arg)
+				}
+			}
+		}
+
+		// Sans "--", les révisions viennent en premier et le premier argument
+		// qui n'en est pas une commence la liste des chemins
+		revCount := len(args)
+		if !hasSeparator {
+			for i, arg := range args {
+				_, _, isRange, _ := repository.ParseDiffRange(arg)
+				if _, err := repository.ResolveRevision(arg); !isRange && err != nil {
+					revCount = i
+					break
+				}
+			}
+			opts.Paths = args[revCount:]
+			for _, path := range opts.Paths {
+				if _, err := os.Lstat(path); err != nil {
+					fmt.Printf("fatal: ambiguous argument '%s': unknown revision or path not in the working tree\n", path)
+					fmt.Println("Use '--' to separate paths from revisions, like this: goit diff [<rev>...] -- [<path>...]")
+					return
+				}
+			}
+		}
+		opts.Revs = args[:revCount]
+
+		if err := status.ShowDiff(opts); err != nil {
+			fmt.Printf("Error: %v\n", err)
 		}
-		status.ShowDiff(filename)
 	case "merge":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: goit merge <branch>")
+		ffMode := merge.FastForward
+		var target string
+		for _, arg := range os.Args[2:] {
+			switch arg {
+			case "--ff":
+				ffMode = merge.FastForward
+			case "--ff-only":
+				ffMode = merge.FastForwardOnly
+			case "--no-ff":
+				ffMode = merge.NoFastForward
+			case "--abort":
+				if err := merge.Abort(); err != nil {
+					fmt.Printf("Error: %v\n", err)
+				} else {
+					fmt.Println("Merge aborted")
+				}
+				return
+			default:
+				target = arg
+			}
+		}
+		if target == "" {
+			fmt.Println("Usage: goit merge [--ff-only|--no-ff] <branch>")
+			return
+		}
+		if err := merge.Merge(target, ffMode); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "merge-base":
+		all := false
+		var revs []string
+		for _, arg := range os.Args[2:] {
+			if arg == "--all" || arg == "-a" {
+				all = true
+			} else {
+				revs = append(revs, arg)
+			}
+		}
+		if len(revs) != 2 {
+			fmt.Println("Usage: goit merge-base [--all] <commit> <commit>")
 			return
 		}
-		if err := merge.Merge(os.Args[2]); err != nil {
+		if err := repository.ShowMergeBase(revs[0], revs[1], all); err != nil {
 			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 	case "resolve":
-		if err := merge.Resolve(); err != nil {
+		force := len(os.Args) > 2 && (os.Args[2] == "--force" || os.Args[2] == "-f")
+		if err := merge.Resolve(force); err != nil {
 			fmt.Printf("Error: %v\n", err)
 		} else {
-			index.CleanupAfterMerge()
 			fmt.Println("Merge resolved successfully")
 		}
+	case "migrate":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: goit migrate <merge-commits|snapshots|objects>")
+			return
+		}
+		var err error
+		switch os.Args[2] {
+		case "merge-commits":
+			err = migrate.RehashMergeCommits()
+		case "snapshots":
+			err = migrate.RebuildSnapshots()
+		case "objects":
+			err = migrate.ConvertObjects()
+		default:
+			fmt.Println("Unknown migration:", os.Args[2])
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "repack":
+		if err := repack.Repack(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "config":
+		global := false
+		var args []string
+		for _, arg := range os.Args[2:] {
+			if arg == "--global" {
+				global = true
+			} else {
+				args = append(args, arg)
+			}
+		}
+		if len(args) < 1 || len(args) > 2 {
+			fmt.Println("Usage: goit config [--global] <key> [<value>]")
+			return
+		}
+		if !global && !repository.IsGoitRepo() {
+			fmt.Println("fatal: not a goit repository (or any of the parent directories)")
+			os.Exit(1)
+		}
+		var value *string
+		if len(args) == 2 {
+			value = &args[1]
+		}
+		if err := config.Config(args[0], value, global); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 	case "help":
 		printHelp()
 	default:
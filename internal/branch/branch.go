@@ -5,12 +5,14 @@ import (
 	"os"
 	"path/filepath"
 	"projet-go-git/internal/repository"
-	"strings"
 )
 
-func Create(name string) {
-	if strings.Contains(name, "/") || strings.Contains(name, " ") {
-		fmt.Printf("Invalid branch name: %s\n", name)
+/**
+ * Crée une branche sur un commit (HEAD par défaut, ou toute révision : tag, hash, HEAD~n...)
+ */
+func Create(name, startPoint string) {
+	if err := repository.CheckRefName(name); err != nil {
+		fmt.Printf("Invalid branch name: %s (%v)\n", name, err)
 		return
 	}
 
@@ -21,13 +23,20 @@ func Create(name string) {
 		return
 	}
 
-	currentHash, err := repository.GetCurrentCommitHash()
-	if err != nil || currentHash == "" {
-		fmt.Println("Cannot create branch: no commits yet")
+	if startPoint == "" {
+		startPoint = "HEAD"
+	}
+	currentHash, err := repository.ResolveRevision(startPoint)
+	if err != nil {
+		if startPoint == "HEAD" {
+			fmt.Println("Cannot create branch: no commits yet")
+		} else {
+			fmt.Printf("Cannot create branch: %v\n", err)
+		}
 		return
 	}
 
-	if err := os.WriteFile(branchPath, []byte(currentHash), 0644); err != nil {
+	if err := repository.UpdateRef("refs/heads/"+name, currentHash, "branch: Created from "+startPoint); err != nil {
 		fmt.Printf("Failed to create branch: %v\n", err)
 		return
 	}
@@ -44,6 +53,11 @@ func List() {
 	}
 
 	currentBranch, _ := repository.GetCurrentBranch()
+	if repository.IsDetached() {
+		if hash, err := repository.GetCurrentCommitHash(); err == nil && len(hash) >= 8 {
+			fmt.Printf("* (HEAD detached at %s)\n", hash[:8])
+		}
+	}
 
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -4,232 +4,512 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"projet-go-git/internal/index"
 	"projet-go-git/internal/objects"
 	"projet-go-git/internal/repository"
+	"sort"
 	"strings"
 )
 
 /**
  * Checkout change de branche et met à jour les fichiers
- * Empêche le checkout si il y a des modifications non commitées
+ * Si target n'est pas une branche (hash, hash court, tag, HEAD~n...), HEAD est détaché sur ce commit
+ * Seuls les fichiers qui diffèrent entre les deux commits sont modifiés,
+ * le checkout est refusé s'il écraserait des modifications locales ou des fichiers non suivis
  */
-func Checkout(branchName string) error {
-	// Vérifier qu'il n'y a pas de modifications non commitées
-	if hasUncommittedChanges() {
-		return fmt.Errorf("error: Your local changes would be overwritten by checkout.\nPlease commit your changes before switching branches.")
+func Checkout(target string) error {
+	if _, err := os.Stat(filepath.Join(".goit", "MERGE_HEAD")); err == nil {
+		return fmt.Errorf("cannot checkout while a merge is in progress (finish it with goit resolve or goit merge --abort)")
 	}
 
-	branchPath := filepath.Join(".goit", "refs", "heads", branchName)
-	if _, err := os.Stat(branchPath); err != nil {
-		return fmt.Errorf("branch %s does not exist", branchName)
+	branchPath := filepath.Join(".goit", "refs", "heads", target)
+	isBranch := false
+	var targetHash string
+	if branchData, err := os.ReadFile(branchPath); err == nil {
+		isBranch = true
+		targetHash = strings.TrimSpace(string(branchData))
+	} else {
+		hash, err := repository.ResolveRevision(target)
+		if err != nil {
+			return fmt.Errorf("pathspec '%s' did not match any branch, tag or commit", target)
+		}
+		targetHash = hash
 	}
 
-	// Mettre à jour HEAD
-	headRef := fmt.Sprintf("ref: refs/heads/%s", branchName)
-	if err := os.WriteFile(".goit/HEAD", []byte(headRef), 0644); err != nil {
-		return fmt.Errorf("failed to update HEAD: %v", err)
+	currentHash, err := repository.GetCurrentCommitHash()
+	if err != nil {
+		return err
 	}
 
-	// Mettre à jour les fichiers selon la nouvelle branche
-	if err := updateWorkingDirectory(branchName); err != nil {
-		return fmt.Errorf("failed to update working directory: %v", err)
+	// Mettre à jour les fichiers avant HEAD : en cas de refus, rien n'a changé
+	if err := UpdateWorkingTree(currentHash, targetHash, "checkout"); err != nil {
+		return err
+	}
+
+	from, _ := repository.GetCurrentBranch()
+	if from == "HEAD" && len(currentHash) >= 8 {
+		from = currentHash[:8]
 	}
 
-	fmt.Printf("Switched to branch %s\n", branchName)
+	newHead := targetHash
+	if isBranch {
+		newHead = fmt.Sprintf("ref: refs/heads/%s", target)
+	}
+	if err := repository.SetHEAD(newHead); err != nil {
+		return fmt.Errorf("failed to update HEAD: %v", err)
+	}
+	// Comme pour UpdateRef, HEAD est déjà déplacé : l'échec du reflog est signalé sans annuler le checkout
+	if err := repository.AppendReflog("HEAD", currentHash, targetHash, fmt.Sprintf("checkout: moving from %s to %s", from, target)); err != nil {
+		return err
+	}
+
+	if isBranch {
+		fmt.Printf("Switched to branch %s\n", target)
+		return nil
+	}
+	fmt.Printf("HEAD is now at %s %s\n", targetHash[:8], commitTitle(targetHash))
+	fmt.Println("You are in 'detached HEAD' state. New commits will not belong to any branch;")
+	fmt.Println("create a branch with 'goit branch <name>' to keep them.")
 	return nil
 }
 
 /**
- * Vérifie s'il y a des modifications non commitées
+ * Première ligne du message d'un commit
  */
-func hasUncommittedChanges() bool {
-	// Vérifier l'index
-	indexPath := filepath.Join(".goit", "index")
-	if _, err := os.Stat(indexPath); err == nil {
-		indexContent, err := os.ReadFile(indexPath)
-		if err == nil && len(strings.TrimSpace(string(indexContent))) > 0 {
-			return true
-		}
+func commitTitle(commitHash string) string {
+	commit, err := objects.ReadCommit(commitHash)
+	if err != nil {
+		return ""
 	}
+	return commit.Title()
+}
 
-	// Vérifier les fichiers modifiés par rapport au dernier commit
-	currentHash, err := repository.GetCurrentCommitHash()
-	if err != nil || currentHash == "" {
-		return false // Pas de commit, donc pas de modifications
-	}
+/**
+ * Fichier du répertoire de travail : son entrée et son contenu
+ */
+type workingFile struct {
+	entry   objects.FileEntry
+	content []byte
+}
 
-	// Récupérer les fichiers du dernier commit
-	commitFiles := getCommitFiles(currentHash)
+/**
+ * Indique si le fichier correspond à une entrée d'arbre ou d'index
+ * Une entrée écrite par une ancienne version (hash du contenu sans en-tête) correspond aussi
+ */
+func (w workingFile) matches(entry objects.FileEntry) bool {
+	return w.entry == entry || objects.IsLegacyMatch(entry, w.entry, w.content)
+}
 
-	// Vérifier si des fichiers ont changé
-	for filename := range commitFiles {
-		if hasFileChanged(filename) {
-			return true
-		}
+/**
+ * Lit un fichier du répertoire de travail
+ * Retourne false si le fichier n'existe pas (ou est un répertoire)
+ */
+func workingEntry(path string) (workingFile, bool) {
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return workingFile{}, false
 	}
-
-	return false
+	entry, content, err := objects.ReadWorkingFile(path)
+	if err != nil {
+		return workingFile{}, false
+	}
+	return workingFile{entry: entry, content: content}, true
 }
 
 /**
- * Récupère les fichiers d'un commit
+ * Changements à appliquer pour passer d'un commit à un autre
  */
-func getCommitFiles(commitHash string) map[string]string {
-	files := make(map[string]string)
+type treeUpdate struct {
+	removed     []string
+	written     map[string]objects.FileEntry
+	localDirty  []string
+	untracked   []string
+	indexResult map[string]objects.FileEntry
+}
 
-	// Lire le commit
-	commitPath := filepath.Join(".goit", "objects", commitHash)
-	commitData, err := os.ReadFile(commitPath)
+/**
+ * Compare les arbres de deux commits et vérifie que le répertoire de travail peut être mis à jour
+ * Un chemin identique dans les deux arbres n'est pas touché (ses modifications locales sont conservées),
+ * un chemin qui change doit être propre dans l'index et le répertoire de travail
+ */
+func planUpdate(fromHash, toHash string) (*treeUpdate, error) {
+	fromFiles, err := repository.CommitFiles(fromHash)
 	if err != nil {
-		return files
+		return nil, err
 	}
-
-	// Extraire le tree hash
-	lines := strings.Split(string(commitData), "\n")
-	var treeHash string
-	for _, line := range lines {
-		if strings.HasPrefix(line, " tree ") {
-			treeHash = strings.TrimPrefix(line, " tree ")
-			break
-		}
+	toFiles, err := repository.CommitFiles(toHash)
+	if err != nil {
+		return nil, err
+	}
+	indexFiles, err := index.ReadFiles()
+	if err != nil {
+		return nil, err
 	}
 
-	if treeHash == "" {
-		return files
+	update := &treeUpdate{
+		written:     make(map[string]objects.FileEntry),
+		indexResult: make(map[string]objects.FileEntry),
+	}
+	for path, entry := range indexFiles {
+		update.indexResult[path] = entry
 	}
 
-	// Lire le tree
-	treePath := filepath.Join(".goit", "objects", treeHash)
-	treeData, err := os.ReadFile(treePath)
-	if err != nil {
-		return files
+	paths := make(map[string]bool)
+	for path := range fromFiles {
+		paths[path] = true
+	}
+	for path := range toFiles {
+		paths[path] = true
 	}
 
-	// Parser le tree
-	treeLines := strings.Split(string(treeData), "\n")
-	for _, line := range treeLines {
-		if line != "" && !strings.HasPrefix(line, "tree") {
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				hash, filename := parts[0], parts[1]
-				files[filename] = hash
+	for path := range paths {
+		from, inFrom := fromFiles[path]
+		to, inTo := toFiles[path]
+		if inFrom && inTo && from == to {
+			continue
+		}
+
+		staged, inIndex := indexFiles[path]
+		working, inWorktree := workingEntry(path)
+
+		// Déjà dans l'état voulu (ex : fichier restauré à la main)
+		alreadyTarget := inIndex == inTo && staged == to && inWorktree == inTo && (!inWorktree || working.matches(to))
+
+		switch {
+		case alreadyTarget:
+		case inFrom:
+			if inIndex != inFrom || staged != from || (inWorktree && !working.matches(from)) {
+				update.localDirty = append(update.localDirty, path)
 			}
+		case inIndex:
+			// Nouveau fichier stagé mais pas encore commité
+			update.localDirty = append(update.localDirty, path)
+		case inWorktree && !working.matches(to):
+			update.untracked = append(update.untracked, path)
+		}
+
+		if inTo {
+			update.written[path] = to
+			update.indexResult[path] = to
+			update.untracked = append(update.untracked, blockingPaths(path, fromFiles)...)
+		} else {
+			update.removed = append(update.removed, path)
+			delete(update.indexResult, path)
 		}
 	}
 
-	return files
+	sort.Strings(update.localDirty)
+	sort.Strings(update.untracked)
+	return update, nil
 }
 
 /**
- * Vérifie si un fichier a changé par rapport au dernier commit
+ * Cherche les fichiers non suivis qui empêchent d'écrire un chemin :
+ * un fichier à la place d'un de ses répertoires parents, ou un répertoire à sa place
  */
-func hasFileChanged(filename string) bool {
-	// Lire le fichier actuel
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return false // Fichier supprimé ou inaccessible
-	}
+func blockingPaths(path string, tracked map[string]objects.FileEntry) []string {
+	var blocking []string
 
-	// Calculer le hash actuel
-	hash := objects.HashContent(string(content))
+	for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+		if info, err := os.Lstat(dir); err == nil && !info.IsDir() {
+			if _, isTracked := tracked[dir]; !isTracked {
+				blocking = append(blocking, dir)
+			}
+		}
+	}
 
-	// Récupérer le hash du dernier commit
-	currentHash, err := repository.GetCurrentCommitHash()
-	if err != nil || currentHash == "" {
-		return false
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		filepath.Walk(path, func(sub string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				if _, isTracked := tracked[sub]; !isTracked {
+					blocking = append(blocking, sub)
+				}
+			}
+			return nil
+		})
 	}
 
-	commitFiles := getCommitFiles(currentHash)
-	expectedHash, exists := commitFiles[filename]
+	return blocking
+}
 
-	if !exists {
-		return true // Nouveau fichier
+/**
+ * Erreur listant les modifications locales ou fichiers non suivis qu'une opération
+ * ("checkout", "merge") écraserait, avec un conseil formulé pour cette opération
+ */
+func (u *treeUpdate) overwriteError(operation string) error {
+	action := operation
+	if operation == "checkout" {
+		action = "switch branches"
 	}
-
-	return hash != expectedHash
+	if len(u.localDirty) > 0 {
+		return fmt.Errorf("Your local changes to the following files would be overwritten by %s:\n\t%s\nPlease commit your changes before you %s.",
+			operation, strings.Join(u.localDirty, "\n\t"), action)
+	}
+	if len(u.untracked) > 0 {
+		return fmt.Errorf("The following untracked working tree files would be overwritten by %s:\n\t%s\nPlease move or remove them before you %s.",
+			operation, strings.Join(u.untracked, "\n\t"), action)
+	}
+	return nil
 }
 
 /**
- * Met à jour le répertoire de travail selon la branche
+ * Vérifie, sans rien modifier, que UpdateWorkingTree(fromHash, toHash, operation) n'écraserait
+ * ni modification locale ni fichier non suivi
  */
-func updateWorkingDirectory(branchName string) error {
-	// Récupérer le hash du commit de la branche
-	branchPath := filepath.Join(".goit", "refs", "heads", branchName)
-	branchData, err := os.ReadFile(branchPath)
+func CheckUpdate(fromHash, toHash, operation string) error {
+	update, err := planUpdate(fromHash, toHash)
 	if err != nil {
 		return err
 	}
-	branchHash := strings.TrimSpace(string(branchData))
+	return update.overwriteError(operation)
+}
 
-	// Nettoyer le répertoire de travail (supprimer tous les fichiers suivis)
-	if err := cleanWorkingDirectory(); err != nil {
+/**
+ * Vérifie qu'un merge de toHash dans fromHash peut modifier le répertoire de travail :
+ * l'index doit correspondre à fromHash et les chemins qui diffèrent entre les deux commits
+ * ne doivent avoir ni modification locale ni fichier non suivi à leur place
+ */
+func CheckMergeable(fromHash, toHash string) error {
+	if err := CheckUpdate(fromHash, toHash, "merge"); err != nil {
 		return err
 	}
 
-	// Restaurer les fichiers de la branche
-	if err := restoreFilesFromCommit(branchHash); err != nil {
+	indexFiles, err := index.ReadFiles()
+	if err != nil {
+		return err
+	}
+	fromFiles, err := repository.CommitFiles(fromHash)
+	if err != nil {
 		return err
 	}
+	var staged []string
+	for path, entry := range indexFiles {
+		if from, exists := fromFiles[path]; !exists || from != entry {
+			staged = append(staged, path)
+		}
+	}
+	for path := range fromFiles {
+		if _, exists := indexFiles[path]; !exists {
+			staged = append(staged, path)
+		}
+	}
+	if len(staged) > 0 {
+		sort.Strings(staged)
+		return fmt.Errorf("Your index contains uncommitted changes:\n\t%s\nPlease commit them before merging.",
+			strings.Join(staged, "\n\t"))
+	}
+	return nil
+}
 
-	// Vider l'index
-	return os.WriteFile(filepath.Join(".goit", "index"), []byte(""), 0644)
+/**
+ * Supprime les répertoires parents devenus vides après la suppression d'un fichier
+ */
+func removeEmptyParents(path string) {
+	for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
 }
 
 /**
- * Nettoie le répertoire de travail
+ * Fait passer le répertoire de travail et l'index du commit fromHash au commit toHash
+ * Seuls les fichiers qui diffèrent entre les deux arbres sont supprimés ou réécrits ;
+ * les fichiers non suivis et les modifications locales des autres fichiers sont conservés.
+ * Refuse (sans rien modifier) si des modifications locales ou des fichiers non suivis seraient écrasés ;
+ * operation ("checkout", "merge") sert au message d'erreur.
  */
-func cleanWorkingDirectory() error {
-	return filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+func UpdateWorkingTree(fromHash, toHash, operation string) error {
+	update, err := planUpdate(fromHash, toHash)
+	if err != nil {
+		return err
+	}
+	if err := update.overwriteError(operation); err != nil {
+		return err
+	}
+
+	// Les suppressions d'abord : un fichier peut être remplacé par un répertoire du même nom
+	for _, path := range update.removed {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
 		}
+		removeEmptyParents(path)
+	}
+	for path, entry := range update.written {
+		if err := objects.WriteWorkingFile(path, entry); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
 
-		// Ignorer les dossiers spéciaux
-		if info.IsDir() {
-			if path == ".goit" || strings.HasPrefix(path, ".goit/") {
-				return filepath.SkipDir
-			}
-			return nil
+	return index.WriteEntries(update.indexResult)
+}
+
+/**
+ * Force le répertoire de travail et l'index sur l'arbre du commit toHash
+ * Contrairement à UpdateWorkingTree, les modifications locales des fichiers suivis sont écrasées
+ * et les fichiers suivis absents de toHash sont supprimés ; les fichiers non suivis restent en place
+ * sauf s'ils occupent un chemin de toHash
+ */
+func ResetWorkingTree(fromHash, toHash string) error {
+	fromFiles, err := repository.CommitFiles(fromHash)
+	if err != nil {
+		return err
+	}
+	toFiles, err := repository.CommitFiles(toHash)
+	if err != nil {
+		return err
+	}
+	indexFiles, err := index.ReadFiles()
+	if err != nil {
+		return err
+	}
+	// Pendant un merge en conflit, un chemin peut n'exister qu'aux étapes 1 à 3
+	// (fichier modifié d'un côté et supprimé de l'autre) : il est suivi lui aussi
+	unmerged, err := index.UnmergedPaths()
+	if err != nil {
+		return err
+	}
+	tracked := make(map[string]bool)
+	for _, files := range []map[string]objects.FileEntry{fromFiles, indexFiles} {
+		for path := range files {
+			tracked[path] = true
 		}
+	}
+	for _, path := range unmerged {
+		tracked[path] = true
+	}
 
-		// Ignorer les fichiers spéciaux
-		if path == "goit" || strings.HasPrefix(path, ".git") {
-			return nil
+	// Fichiers suivis (dans HEAD, dans l'index ou en conflit) qui disparaissent
+	for path := range tracked {
+		if _, kept := toFiles[path]; kept {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
 		}
+		removeEmptyParents(path)
+	}
 
-		// Supprimer le fichier
-		return os.Remove(path)
-	})
+	for path, entry := range toFiles {
+		if current, exists := workingEntry(path); exists && current.matches(entry) {
+			continue
+		}
+		// Un fichier à la place d'un répertoire parent, ou un répertoire à la place du fichier, doit disparaître
+		for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+			if info, err := os.Lstat(dir); err == nil && !info.IsDir() {
+				os.Remove(dir)
+			}
+		}
+		if info, err := os.Lstat(path); err == nil && info.IsDir() {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %v", path, err)
+			}
+		}
+		if err := objects.WriteWorkingFile(path, entry); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+
+	return index.WriteEntries(toFiles)
 }
 
 /**
- * Restaure les fichiers d'un commit
+ * Ramène l'index sur l'arbre du commit toHash et le répertoire de travail sur les seuls chemins
+ * touchés : ceux dont l'index diffère de toHash et, pendant un merge, ceux qui diffèrent entre
+ * toHash et le commit fusionné mergeHash (fichiers écrits par le merge, marqueurs de conflit compris).
+ * Les modifications non indexées des autres fichiers sont conservées ; un fichier à remettre
+ * qui a aussi des modifications non indexées fait échouer l'opération sans rien modifier.
  */
-func restoreFilesFromCommit(commitHash string) error {
-	// Récupérer les fichiers du commit
-	files := getCommitFiles(commitHash)
-
-	for filename, hash := range files {
-		// Lire le contenu du fichier depuis les objets
-		objectPath := filepath.Join(".goit", "objects", hash)
-		content, err := os.ReadFile(objectPath)
-		if err != nil {
-			continue
+func ResetMergeWorkingTree(toHash, mergeHash string) error {
+	toFiles, err := repository.CommitFiles(toHash)
+	if err != nil {
+		return err
+	}
+	indexFiles, err := index.ReadFiles()
+	if err != nil {
+		return err
+	}
+
+	touched := make(map[string]bool)
+	for _, files := range []map[string]objects.FileEntry{toFiles, indexFiles} {
+		for path := range files {
+			staged, inIndex := indexFiles[path]
+			target, inTarget := toFiles[path]
+			if inIndex != inTarget || staged != target {
+				touched[path] = true
+			}
 		}
+	}
 
-		// Créer les dossiers parents si nécessaire
-		dir := filepath.Dir(filename)
-		if dir != "." {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				continue
+	// Les chemins en conflit ont été écrits par le merge (marqueurs ou version conservée d'un côté)
+	mergeTouched := make(map[string]bool)
+	unmerged, err := index.UnmergedPaths()
+	if err != nil {
+		return err
+	}
+	for _, path := range unmerged {
+		mergeTouched[path] = true
+		touched[path] = true
+	}
+	if mergeHash != "" {
+		mergeFiles, err := repository.CommitFiles(mergeHash)
+		if err != nil {
+			return err
+		}
+		for _, files := range []map[string]objects.FileEntry{toFiles, mergeFiles} {
+			for path := range files {
+				theirs, inMerge := mergeFiles[path]
+				target, inTarget := toFiles[path]
+				if inMerge != inTarget || theirs != target {
+					mergeTouched[path] = true
+					touched[path] = true
+				}
 			}
 		}
+	}
 
-		// Écrire le fichier
-		if err := os.WriteFile(filename, content, 0644); err != nil {
+	var notUpToDate []string
+	for path := range touched {
+		if mergeTouched[path] {
 			continue
 		}
+		staged, inIndex := indexFiles[path]
+		working, inWorktree := workingEntry(path)
+		if inWorktree != inIndex || (inWorktree && !working.matches(staged)) {
+			notUpToDate = append(notUpToDate, path)
+		}
+	}
+	if len(notUpToDate) > 0 {
+		sort.Strings(notUpToDate)
+		return fmt.Errorf("Entry '%s' not uptodate. Cannot reset.", strings.Join(notUpToDate, "', '"))
 	}
 
-	return nil
+	for path := range touched {
+		if _, kept := toFiles[path]; kept {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
+		}
+		removeEmptyParents(path)
+	}
+	for path := range touched {
+		entry, kept := toFiles[path]
+		if !kept {
+			continue
+		}
+		if current, exists := workingEntry(path); exists && current.matches(entry) {
+			continue
+		}
+		if info, err := os.Lstat(path); err == nil && info.IsDir() {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %v", path, err)
+			}
+		}
+		if err := objects.WriteWorkingFile(path, entry); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+
+	return index.WriteEntries(toFiles)
 }
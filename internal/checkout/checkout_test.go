@@ -0,0 +1,85 @@
+package checkout
+
+import (
+	"os"
+	"path/filepath"
+	"projet-go-git/internal/objects"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+ * Dépôt écrit par une ancienne version : objets plats sans en-tête, arbres au format plat
+ * main contient a = "a\n", feat contient a = "a2\n"
+ */
+func writeLegacyRepo(t *testing.T) (mainHash, featHash string) {
+	t.Helper()
+	for _, dir := range []string{".goit/objects", ".goit/refs/heads"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(raw string) string {
+		hash := objects.LegacyBlobHash([]byte(raw))
+		if err := os.WriteFile(filepath.Join(".goit", "objects", hash), []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	commit := func(content, message, parent string) string {
+		tree := write("tree\n" + write(content) + " a\n")
+		header := "commit\n tree " + tree + "\n"
+		if parent != "" {
+			header += " parent " + parent + "\n"
+		}
+		return write(header + " date 2024-01-01T00:00:00Z\n\n " + message)
+	}
+
+	mainHash = commit("a\n", "a", "")
+	featHash = commit("a2\n", "a2", mainHash)
+	os.WriteFile(".goit/refs/heads/main", []byte(mainHash), 0644)
+	os.WriteFile(".goit/refs/heads/feat", []byte(featHash), 0644)
+	os.WriteFile(".goit/HEAD", []byte("ref: refs/heads/main"), 0644)
+	os.WriteFile("a", []byte("a\n"), 0644)
+	return mainHash, featHash
+}
+
+func TestUpdateWorkingTreeWithLegacyTree(t *testing.T) {
+	t.Chdir(t.TempDir())
+	mainHash, featHash := writeLegacyRepo(t)
+
+	// Le fichier correspond au hash sans en-tête de l'arbre : ce n'est pas une modification locale
+	if err := CheckMergeable(mainHash, featHash); err != nil {
+		t.Fatalf("merge refused on a clean legacy tree: %v", err)
+	}
+	if err := UpdateWorkingTree(mainHash, featHash, "checkout"); err != nil {
+		t.Fatalf("checkout refused on a clean legacy tree: %v", err)
+	}
+	if data, _ := os.ReadFile("a"); string(data) != "a2\n" {
+		t.Fatalf("a = %q after checkout", data)
+	}
+
+	// Une vraie modification locale est toujours détectée
+	os.WriteFile("a", []byte("local\n"), 0644)
+	if err := UpdateWorkingTree(featHash, mainHash, "checkout"); err == nil || !strings.Contains(err.Error(), "would be overwritten") {
+		t.Fatalf("local change not detected: %v", err)
+	}
+	if err := ResetMergeWorkingTree(featHash, ""); err != nil {
+		t.Fatalf("reset --merge: %v", err)
+	}
+	if data, _ := os.ReadFile("a"); string(data) != "local\n" {
+		t.Errorf("reset --merge touched an unstaged change: a = %q", data)
+	}
+
+	// Un fichier identique au contenu de l'arbre n'est pas réécrit par reset --hard
+	os.WriteFile("a", []byte("a2\n"), 0644)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes("a", old, old)
+	if err := ResetWorkingTree(featHash, featHash); err != nil {
+		t.Fatalf("reset --hard: %v", err)
+	}
+	if info, err := os.Stat("a"); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("reset --hard rewrote an unchanged file")
+	}
+}
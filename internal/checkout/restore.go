@@ -0,0 +1,137 @@
+package checkout
+
+import (
+	"fmt"
+	"os"
+	"projet-go-git/internal/index"
+	"projet-go-git/internal/objects"
+	"projet-go-git/internal/repository"
+	"sort"
+)
+
+/**
+ * Restaure des fichiers dans l'index et/ou le répertoire de travail
+ * - source : révision d'origine ; par défaut l'index pour le répertoire de travail, HEAD pour l'index
+ * - staged : restaure les entrées de l'index
+ * - worktree : restaure les fichiers du répertoire de travail (par défaut si staged n'est pas demandé)
+ * Un fichier absent de la source est retiré de l'index et/ou supprimé du répertoire de travail
+ */
+func Restore(paths []string, source string, staged, worktree bool) error {
+	if !staged {
+		worktree = true
+	}
+
+	pathspecs := index.CleanPathspecs(paths)
+
+	indexFiles, err := index.ReadFiles()
+	if err != nil {
+		return err
+	}
+	conflicts, err := index.ReadConflicts()
+	if err != nil {
+		return err
+	}
+
+	// Déterminer l'arbre source
+	sourceName := "the index"
+	sourceFiles := indexFiles
+	if source != "" || staged {
+		if source == "" {
+			source = "HEAD"
+		}
+		sourceName = source
+		sourceFiles = make(map[string]objects.FileEntry)
+		commitHash, err := repository.ResolveRevision(source)
+		if err != nil && source != "HEAD" {
+			return err
+		}
+		if err == nil {
+			if sourceFiles, err = objects.ReadCommitFiles(commitHash); err != nil {
+				return err
+			}
+			sourceName = commitHash[:8]
+		}
+	}
+
+	// Un chemin en conflit n'a pas de version dans l'index à restaurer
+	if source == "" {
+		for path := range conflicts {
+			if index.MatchesPathspec(path, pathspecs) {
+				return fmt.Errorf("path '%s' is unmerged", path)
+			}
+		}
+	}
+
+	// Chemins concernés : présents dans la source, dans l'index ou en conflit
+	matched := make(map[string]bool)
+	for path := range conflicts {
+		if index.MatchesPathspec(path, pathspecs) {
+			matched[path] = true
+		}
+	}
+	for path := range sourceFiles {
+		if index.MatchesPathspec(path, pathspecs) {
+			matched[path] = true
+		}
+	}
+	for path := range indexFiles {
+		if index.MatchesPathspec(path, pathspecs) {
+			matched[path] = true
+		}
+	}
+	for i, spec := range pathspecs {
+		found := false
+		for path := range matched {
+			if index.MatchesPathspec(path, []string{spec}) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("pathspec '%s' did not match any file(s) known to goit", paths[i])
+		}
+	}
+
+	var sorted []string
+	for path := range matched {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	for _, path := range sorted {
+		entry, inSource := sourceFiles[path]
+
+		if worktree {
+			if inSource {
+				if current, exists := workingEntry(path); !exists || !current.matches(entry) {
+					if err := objects.WriteWorkingFile(path, entry); err != nil {
+						return fmt.Errorf("failed to restore %s: %v", path, err)
+					}
+				}
+			} else if _, tracked := indexFiles[path]; tracked {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to remove %s: %v", path, err)
+				}
+				removeEmptyParents(path)
+			}
+		}
+
+		if staged {
+			if inSource {
+				indexFiles[path] = entry
+			} else {
+				delete(indexFiles, path)
+			}
+			delete(conflicts, path)
+		}
+	}
+
+	if staged {
+		if err := index.WriteEntriesWithConflicts(indexFiles, conflicts); err != nil {
+			return fmt.Errorf("failed to write index: %v", err)
+		}
+	}
+
+	fmt.Printf("Updated %d path(s) from %s\n", len(sorted), sourceName)
+	return nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/**
+ * Fichier de configuration du dépôt, au format :
+ * [section]
+ *     cle = valeur
+ * Une clé est désignée par "section.cle" (ex : user.name)
+ */
+func repoConfigPath() string {
+	return filepath.Join(".goit", "config")
+}
+
+/**
+ * Fichier de configuration global de l'utilisateur (~/.goitconfig)
+ */
+func globalConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".goitconfig")
+}
+
+/**
+ * Sépare une clé "section.cle" en section et nom
+ */
+func splitKey(key string) (string, string, error) {
+	section, name, found := strings.Cut(strings.ToLower(key), ".")
+	if !found || section == "" || name == "" {
+		return "", "", fmt.Errorf("invalid config key %q (expected section.name)", key)
+	}
+	return section, name, nil
+}
+
+/**
+ * Lit un fichier de configuration (section.cle -> valeur)
+ */
+func readConfigFile(path string) map[string]string {
+	values := make(map[string]string)
+	if path == "" {
+		return values
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return values
+	}
+
+	section := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		name, value, _ := strings.Cut(line, "=")
+		values[section+"."+strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+/**
+ * Récupère une valeur de configuration
+ * La configuration du dépôt est prioritaire sur la configuration globale
+ */
+func Get(key string) (string, bool) {
+	key = strings.ToLower(key)
+	if value, ok := readConfigFile(repoConfigPath())[key]; ok {
+		return value, true
+	}
+	value, ok := readConfigFile(globalConfigPath())[key]
+	return value, ok
+}
+
+/**
+ * Enregistre une valeur dans la configuration du dépôt ou dans la configuration globale
+ * Les autres lignes du fichier (commentaires compris) sont conservées
+ */
+func Set(key, value string, global bool) error {
+	section, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	path := repoConfigPath()
+	if global {
+		if path = globalConfigPath(); path == "" {
+			return fmt.Errorf("cannot locate home directory")
+		}
+	}
+
+	var lines []string
+	if content, err := os.ReadFile(path); err == nil {
+		lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read %s: %v", path, err)
+	}
+
+	entry := fmt.Sprintf("\t%s = %s", name, value)
+	current, sectionEnd := "", -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			current = strings.ToLower(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			if current == section {
+				sectionEnd = i + 1
+			}
+			continue
+		}
+		if current != section {
+			continue
+		}
+		sectionEnd = i + 1
+		if lineName, _, found := strings.Cut(trimmed, "="); found && strings.ToLower(strings.TrimSpace(lineName)) == name {
+			lines[i] = entry
+			return writeConfigFile(path, lines)
+		}
+	}
+
+	if sectionEnd < 0 {
+		lines = append(lines, "["+section+"]", entry)
+	} else {
+		lines = append(lines[:sectionEnd], append([]string{entry}, lines[sectionEnd:]...)...)
+	}
+	return writeConfigFile(path, lines)
+}
+
+func writeConfigFile(path string, lines []string) error {
+	content := strings.TrimLeft(strings.Join(lines, "\n"), "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %v", path, err)
+	}
+	return nil
+}
+
+/**
+ * Affiche ou modifie une valeur de configuration (commande goit config)
+ */
+func Config(key string, value *string, global bool) error {
+	if value != nil {
+		return Set(key, *value, global)
+	}
+
+	var found bool
+	var current string
+	if global {
+		current, found = readConfigFile(globalConfigPath())[strings.ToLower(key)]
+	} else {
+		current, found = Get(key)
+	}
+	if !found {
+		return fmt.Errorf("key %s is not set", key)
+	}
+	fmt.Println(current)
+	return nil
+}
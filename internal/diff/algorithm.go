@@ -0,0 +1,83 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+ * Algorithme de calcul du script d'édition entre deux listes de lignes
+ * Toutes les implémentations produisent un script valide ; elles diffèrent par les lignes
+ * qu'elles choisissent d'aligner quand plusieurs scripts sont possibles
+ */
+type Algorithm interface {
+	Name() string
+	Diff(a, b []string) []Edit
+}
+
+/**
+ * Implémentation commune : retire le préfixe et le suffixe communs, applique middle,
+ * puis place les blocs ambigus le plus bas possible
+ */
+type algorithm struct {
+	name   string
+	middle func(a, b []string) []Edit
+}
+
+func (alg algorithm) Name() string {
+	return alg.name
+}
+
+func (alg algorithm) Diff(a, b []string) []Edit {
+	return slideDown(trimCommon(a, b, alg.middle))
+}
+
+// Coût minimal à partir duquel Myers abandonne la recherche du script le plus court
+const minMaxCost = 256
+
+/**
+ * Algorithmes disponibles
+ * - Myers : script le plus court, avec une limite de coût sur les gros fichiers très différents
+ * - Minimal : Myers sans limite, toujours le script le plus court quel que soit le temps nécessaire
+ * - Patience : aligne d'abord les lignes uniques dans les deux versions (accolades et lignes vides
+ *   ne servent plus de points d'ancrage), utile quand des fonctions ont été déplacées
+ * - Histogram : variante de patience qui ancre sur les lignes les moins fréquentes, même non uniques
+ */
+var (
+	Myers     Algorithm = algorithm{name: "myers", middle: boundedMyers}
+	Minimal   Algorithm = algorithm{name: "minimal", middle: minimalMyers}
+	Patience  Algorithm = algorithm{name: "patience", middle: patience}
+	Histogram Algorithm = algorithm{name: "histogram", middle: histogram}
+)
+
+// Algorithme utilisé quand aucun n'est demandé
+var DefaultAlgorithm = Myers
+
+var algorithms = []Algorithm{Myers, Minimal, Patience, Histogram}
+
+/**
+ * Retourne l'algorithme qui porte ce nom (myers, minimal, patience ou histogram)
+ */
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for _, alg := range algorithms {
+		if strings.EqualFold(name, alg.Name()) {
+			return alg, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown diff algorithm '%s' (expected myers, minimal, patience or histogram)", name)
+}
+
+/**
+ * Myers avec une limite de coût proportionnelle à la racine carrée de la taille des entrées
+ */
+func boundedMyers(a, b []string) []Edit {
+	maxCost := minMaxCost
+	for maxCost*maxCost < len(a)+len(b) {
+		maxCost++
+	}
+	return myers(a, b, maxCost)
+}
+
+func minimalMyers(a, b []string) []Edit {
+	return myers(a, b, 0)
+}
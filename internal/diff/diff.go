@@ -0,0 +1,265 @@
+package diff
+
+import "strings"
+
+/**
+ * Type d'opération d'une ligne dans un script d'édition
+ */
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+/**
+ * Une ligne du script d'édition entre deux versions
+ * OldIndex vaut -1 pour une insertion, NewIndex vaut -1 pour une suppression
+ */
+type Edit struct {
+	Op       Op
+	OldIndex int
+	NewIndex int
+	Line     string
+}
+
+/**
+ * Découpe un contenu en lignes en conservant les retours à la ligne
+ * La dernière ligne peut ne pas se terminer par "\n"
+ */
+func SplitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+/**
+ * Calcule le script d'édition entre deux listes de lignes avec l'algorithme par défaut (Myers)
+ */
+func Lines(a, b []string) []Edit {
+	return DefaultAlgorithm.Diff(a, b)
+}
+
+/**
+ * Retire le préfixe et le suffixe communs puis calcule le script d'édition du milieu avec middle
+ * Les indices du script de middle sont relatifs aux lignes qu'il reçoit
+ */
+func trimCommon(a, b []string, middle func(a, b []string) []Edit) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, Edit{Op: Equal, OldIndex: i, NewIndex: i, Line: a[i]})
+	}
+
+	edits = append(edits, shift(middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]), prefix, prefix)...)
+
+	for i := 0; i < suffix; i++ {
+		oldIndex := len(a) - suffix + i
+		newIndex := len(b) - suffix + i
+		edits = append(edits, Edit{Op: Equal, OldIndex: oldIndex, NewIndex: newIndex, Line: a[oldIndex]})
+	}
+
+	return edits
+}
+
+/**
+ * Décale les indices d'un script d'édition calculé sur a[oldOffset:] et b[newOffset:]
+ */
+func shift(edits []Edit, oldOffset, newOffset int) []Edit {
+	for i := range edits {
+		if edits[i].OldIndex >= 0 {
+			edits[i].OldIndex += oldOffset
+		}
+		if edits[i].NewIndex >= 0 {
+			edits[i].NewIndex += newOffset
+		}
+	}
+	return edits
+}
+
+/**
+ * Script d'édition sans ligne commune : toutes les suppressions puis tous les ajouts
+ */
+func replaceAll(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for i, line := range a {
+		edits = append(edits, Edit{Op: Delete, OldIndex: i, NewIndex: -1, Line: line})
+	}
+	for j, line := range b {
+		edits = append(edits, Edit{Op: Insert, OldIndex: -1, NewIndex: j, Line: line})
+	}
+	return edits
+}
+
+/**
+ * Fait glisser vers le bas les blocs d'ajouts (ou de suppressions) qui peuvent être placés
+ * à plusieurs endroits : tant que la ligne identique qui suit le bloc est égale à sa première ligne,
+ * le bloc descend d'une ligne. Ainsi un bloc de fonctions ajoutées commence à "func" et non à "}"
+ */
+func slideDown(edits []Edit) []Edit {
+	for start := 0; start < len(edits); {
+		op := edits[start].Op
+		end := start + 1
+		if op == Equal {
+			start = end
+			continue
+		}
+		for end < len(edits) && edits[end].Op == op {
+			end++
+		}
+		// Les blocs mêlant ajouts et suppressions ne sont pas déplacés
+		mixed := (start > 0 && edits[start-1].Op != Equal) || (end < len(edits) && edits[end].Op != Equal)
+
+		for !mixed && end < len(edits) && edits[end].Line == edits[start].Line {
+			// La ligne identique remonte au début du bloc, le bloc descend d'une ligne
+			moved := edits[end]
+			if op == Insert {
+				moved.NewIndex = edits[start].NewIndex
+			} else {
+				moved.OldIndex = edits[start].OldIndex
+			}
+			copy(edits[start+1:end+1], edits[start:end])
+			edits[start] = moved
+			for k := start + 1; k <= end; k++ {
+				if op == Insert {
+					edits[k].NewIndex++
+				} else {
+					edits[k].OldIndex++
+				}
+				if k < end {
+					edits[k].Line = edits[k+1].Line
+				} else {
+					edits[k].Line = moved.Line
+				}
+			}
+			start++
+			end++
+			mixed = end < len(edits) && edits[end].Op != Equal
+		}
+		start = end
+	}
+	return edits
+}
+
+/**
+ * Algorithme de Myers en O(ND)
+ * Conserve pour chaque étape d les diagonales atteintes afin de reconstruire le chemin
+ * Si maxCost > 0 et que le script dépasse maxCost modifications, le chemin le plus avancé est conservé
+ * et le reste est recalculé à partir de ce point : le résultat n'est alors plus forcément minimal
+ */
+func myers(a, b []string, maxCost int) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		// Sauvegarde des diagonales -d..d atteintes à l'étape précédente
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+
+		if maxCost > 0 && d >= maxCost {
+			// Point le plus avancé (x + y maximal) à l'intérieur de la grille
+			bestX, bestY := -1, -1
+			for k := -d; k <= d; k += 2 {
+				x := v[offset+k]
+				y := x - k
+				if x <= n && y >= 0 && y <= m && x+y > bestX+bestY {
+					bestX, bestY = x, y
+				}
+			}
+			edits := backtrack(a[:bestX], b[:bestY], trace)
+			return append(edits, shift(myers(a[bestX:], b[bestY:], maxCost), bestX, bestY)...)
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Reconstruit le script d'édition à partir des étapes sauvegardées par myers()
+ */
+func backtrack(a, b []string, trace [][]int) []Edit {
+	x, y := len(a), len(b)
+	var reversed []Edit
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		var prevK, prevX int
+		if d == 0 {
+			prevX = 0
+			prevK = 0
+		} else {
+			snapshot := trace[d]
+			at := func(diag int) int { return snapshot[diag+d] }
+			if k == -d || (k != d && at(k-1) < at(k+1)) {
+				prevK = k + 1
+			} else {
+				prevK = k - 1
+			}
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Edit{Op: Equal, OldIndex: x, NewIndex: y, Line: a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				reversed = append(reversed, Edit{Op: Insert, OldIndex: -1, NewIndex: y, Line: b[y]})
+			} else {
+				x--
+				reversed = append(reversed, Edit{Op: Delete, OldIndex: x, NewIndex: -1, Line: a[x]})
+			}
+		}
+	}
+
+	edits := make([]Edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
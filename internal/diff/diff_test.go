@@ -0,0 +1,319 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/**
+ * Vérifie qu'un script d'édition reconstruit exactement les deux versions
+ * et que ses indices se suivent ; retourne le nombre de lignes modifiées
+ */
+func checkEdits(t *testing.T, a, b []string, edits []Edit) int {
+	t.Helper()
+	var oldLines, newLines []string
+	changes := 0
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			if e.OldIndex != len(oldLines) || e.NewIndex != len(newLines) {
+				t.Fatalf("equal %q at %d/%d, expected %d/%d", e.Line, e.OldIndex, e.NewIndex, len(oldLines), len(newLines))
+			}
+			oldLines = append(oldLines, e.Line)
+			newLines = append(newLines, e.Line)
+		case Delete:
+			if e.OldIndex != len(oldLines) || e.NewIndex != -1 {
+				t.Fatalf("delete %q at %d/%d, expected %d/-1", e.Line, e.OldIndex, e.NewIndex, len(oldLines))
+			}
+			oldLines = append(oldLines, e.Line)
+			changes++
+		case Insert:
+			if e.NewIndex != len(newLines) || e.OldIndex != -1 {
+				t.Fatalf("insert %q at %d/%d, expected -1/%d", e.Line, e.OldIndex, e.NewIndex, len(newLines))
+			}
+			newLines = append(newLines, e.Line)
+			changes++
+		}
+	}
+	if !reflect.DeepEqual(oldLines, a) && len(oldLines)+len(a) > 0 {
+		t.Fatalf("old version rebuilt as %q, expected %q", oldLines, a)
+	}
+	if !reflect.DeepEqual(newLines, b) && len(newLines)+len(b) > 0 {
+		t.Fatalf("new version rebuilt as %q, expected %q", newLines, b)
+	}
+	return changes
+}
+
+/**
+ * Résumé compact d'un script : " " pour une ligne identique, "+" et "-" pour les modifications
+ */
+func editScript(edits []Edit) string {
+	var out strings.Builder
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			out.WriteString(" ")
+		case Insert:
+			out.WriteString("+")
+		case Delete:
+			out.WriteString("-")
+		}
+		out.WriteString(strings.TrimSuffix(e.Line, "\n"))
+		out.WriteString("|")
+	}
+	return out.String()
+}
+
+func TestAlgorithms(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		changes int
+	}{
+		{name: "both empty", old: "", new: "", changes: 0},
+		{name: "old empty", old: "", new: "a\nb\n", changes: 2},
+		{name: "new empty", old: "a\nb\n", new: "", changes: 2},
+		{name: "identical", old: "a\nb\nc\n", new: "a\nb\nc\n", changes: 0},
+		{name: "no trailing newline added", old: "a\nb", new: "a\nb\n", changes: 2},
+		{name: "no trailing newline removed", old: "a\nb\n", new: "a\nb", changes: 2},
+		{name: "change at start", old: "a\nb\nc\n", new: "x\nb\nc\n", changes: 2},
+		{name: "change at end", old: "a\nb\nc\n", new: "a\nb\nx\n", changes: 2},
+		{name: "insert at start", old: "b\nc\n", new: "a\nb\nc\n", changes: 1},
+		{name: "delete at end", old: "a\nb\nc\n", new: "a\nb\n", changes: 1},
+		{name: "all replaced", old: "a\nb\n", new: "c\nd\ne\n", changes: 5},
+		{name: "repeated lines", old: "x\nx\ny\nx\n", new: "x\ny\nx\nx\n", changes: 2},
+		{
+			name:    "moved function",
+			old:     "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n",
+			new:     "func b() {\n\treturn 2\n}\n\nfunc a() {\n\treturn 1\n}\n",
+			changes: 8,
+		},
+	}
+
+	for _, alg := range algorithms {
+		for _, tt := range tests {
+			t.Run(alg.Name()+"/"+tt.name, func(t *testing.T) {
+				a, b := SplitLines(tt.old), SplitLines(tt.new)
+				changes := checkEdits(t, a, b, alg.Diff(a, b))
+				// Myers et Minimal produisent toujours le script le plus court sur ces petites entrées
+				if (alg.Name() == "myers" || alg.Name() == "minimal") && changes != tt.changes {
+					t.Errorf("%d changed lines, expected %d", changes, tt.changes)
+				}
+				if changes < tt.changes {
+					t.Errorf("%d changed lines, fewer than the minimum %d", changes, tt.changes)
+				}
+			})
+		}
+	}
+}
+
+/**
+ * Nombre de blocs de lignes modifiées consécutives dans un script
+ */
+func changeBlocks(edits []Edit) int {
+	blocks := 0
+	for i, e := range edits {
+		if e.Op != Equal && (i == 0 || edits[i-1].Op == Equal) {
+			blocks++
+		}
+	}
+	return blocks
+}
+
+func TestAlgorithmsOnMovedFunction(t *testing.T) {
+	frob := "int frob()\n{\n  loop();\n  {\n    print();\n  }\n}\n\n"
+	fact := "int fact()\n{\n  if()\n  {\n    rec();\n  }\n  return 1;\n}\n\n"
+	main := "int main()\n{\n  frob();\n}\n"
+	old := SplitLines(frob + fact + main)
+	new := SplitLines(fact + frob + main)
+
+	// Patience et Histogram gardent fact() intacte : frob() est supprimée puis ajoutée en un seul bloc,
+	// Myers aligne les accolades des deux fonctions et entrelace leurs lignes
+	tests := []struct {
+		alg    Algorithm
+		blocks int
+	}{
+		{Myers, 8},
+		{Minimal, 8},
+		{Patience, 2},
+		{Histogram, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg.Name(), func(t *testing.T) {
+			edits := tt.alg.Diff(old, new)
+			checkEdits(t, old, new, edits)
+			if got := changeBlocks(edits); got != tt.blocks {
+				t.Errorf("%d blocks of changes, want %d: %s", got, tt.blocks, editScript(edits))
+			}
+		})
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Algorithm
+		wantErr bool
+	}{
+		{name: "myers", want: Myers},
+		{name: "minimal", want: Minimal},
+		{name: "Patience", want: Patience},
+		{name: "HISTOGRAM", want: Histogram},
+		{name: "", wantErr: true},
+		{name: "diff3", wantErr: true},
+	}
+	for _, tt := range tests {
+		alg, err := ParseAlgorithm(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseAlgorithm(%q) accepted, expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil || alg.Name() != tt.want.Name() {
+			t.Errorf("ParseAlgorithm(%q) = %v, %v, want %s", tt.name, alg, err, tt.want.Name())
+		}
+	}
+}
+
+func TestSlideDown(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits []Edit
+		want  []Edit
+	}{
+		{
+			name: "added block moves below the closing brace",
+			edits: []Edit{
+				{Op: Equal, OldIndex: 0, NewIndex: 0, Line: "{\n"},
+				{Op: Insert, OldIndex: -1, NewIndex: 1, Line: "}\n"},
+				{Op: Insert, OldIndex: -1, NewIndex: 2, Line: "{\n"},
+				{Op: Equal, OldIndex: 1, NewIndex: 3, Line: "}\n"},
+				{Op: Equal, OldIndex: 2, NewIndex: 4, Line: "end\n"},
+			},
+			want: []Edit{
+				{Op: Equal, OldIndex: 0, NewIndex: 0, Line: "{\n"},
+				{Op: Equal, OldIndex: 1, NewIndex: 1, Line: "}\n"},
+				{Op: Insert, OldIndex: -1, NewIndex: 2, Line: "{\n"},
+				{Op: Insert, OldIndex: -1, NewIndex: 3, Line: "}\n"},
+				{Op: Equal, OldIndex: 2, NewIndex: 4, Line: "end\n"},
+			},
+		},
+		{
+			name: "deleted block moves to the end of the file",
+			edits: []Edit{
+				{Op: Delete, OldIndex: 0, NewIndex: -1, Line: "x\n"},
+				{Op: Equal, OldIndex: 1, NewIndex: 0, Line: "x\n"},
+			},
+			want: []Edit{
+				{Op: Equal, OldIndex: 0, NewIndex: 0, Line: "x\n"},
+				{Op: Delete, OldIndex: 1, NewIndex: -1, Line: "x\n"},
+			},
+		},
+		{
+			name: "replacement is not moved",
+			edits: []Edit{
+				{Op: Delete, OldIndex: 0, NewIndex: -1, Line: "a\n"},
+				{Op: Insert, OldIndex: -1, NewIndex: 0, Line: "b\n"},
+				{Op: Equal, OldIndex: 1, NewIndex: 1, Line: "a\n"},
+			},
+			want: []Edit{
+				{Op: Delete, OldIndex: 0, NewIndex: -1, Line: "a\n"},
+				{Op: Insert, OldIndex: -1, NewIndex: 0, Line: "b\n"},
+				{Op: Equal, OldIndex: 1, NewIndex: 1, Line: "a\n"},
+			},
+		},
+		{
+			name:  "empty script",
+			edits: nil,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slideDown(tt.edits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %s, want %s", editScript(got), editScript(tt.want))
+			}
+		})
+	}
+}
+
+func TestHunks(t *testing.T) {
+	tenLines := "l1\nl2\nl3\nl4\nl5\nl6\nl7\nl8\nl9\nl10\n"
+
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		headers []string
+	}{
+		{name: "no change", old: tenLines, new: tenLines, headers: nil},
+		{name: "both empty", old: "", new: "", headers: nil},
+		{name: "new file", old: "", new: "a\nb\n", headers: []string{"@@ -0,0 +1,2 @@"}},
+		{name: "deleted file", old: "a\nb\n", new: "", headers: []string{"@@ -1,2 +0,0 @@"}},
+		{
+			name:    "change at start",
+			old:     tenLines,
+			new:     strings.Replace(tenLines, "l1\n", "x\n", 1),
+			headers: []string{"@@ -1,4 +1,4 @@"},
+		},
+		{
+			name:    "change at end",
+			old:     tenLines,
+			new:     strings.Replace(tenLines, "l10\n", "x\n", 1),
+			headers: []string{"@@ -7,4 +7,4 @@"},
+		},
+		{
+			name:    "append at end",
+			old:     tenLines,
+			new:     tenLines + "l11\n",
+			headers: []string{"@@ -8,3 +8,4 @@"},
+		},
+		{
+			name:    "no trailing newline",
+			old:     "a\nb",
+			new:     "a\nb\n",
+			headers: []string{"@@ -1,2 +1,2 @@"},
+		},
+		{
+			name:    "distant changes in two hunks",
+			old:     tenLines,
+			new:     strings.Replace(strings.Replace(tenLines, "l1\n", "x\n", 1), "l10\n", "y\n", 1),
+			headers: []string{"@@ -1,4 +1,4 @@", "@@ -7,4 +7,4 @@"},
+		},
+		{
+			name:    "close changes in one hunk",
+			old:     tenLines,
+			new:     strings.Replace(strings.Replace(tenLines, "l2\n", "x\n", 1), "l9\n", "y\n", 1),
+			headers: []string{"@@ -1,10 +1,10 @@"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := Hunks(Lines(SplitLines(tt.old), SplitLines(tt.new)), DefaultContext)
+			var headers []string
+			for _, h := range hunks {
+				headers = append(headers, h.Header())
+			}
+			if !reflect.DeepEqual(headers, tt.headers) {
+				t.Errorf("got %q, want %q", headers, tt.headers)
+			}
+		})
+	}
+}
+
+func TestHunksWithoutContext(t *testing.T) {
+	edits := Lines(SplitLines("a\nb\nc\n"), SplitLines("a\nx\nc\n"))
+	hunks := Hunks(edits, 0)
+	if len(hunks) != 1 {
+		t.Fatalf("expected one hunk, got %d", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -2 +2 @@" {
+		t.Errorf("got %s, want @@ -2 +2 @@", got)
+	}
+	if got := editScript(hunks[0].Edits); got != "-b|+x|" {
+		t.Errorf("got %s, want -b|+x|", got)
+	}
+}
@@ -0,0 +1,68 @@
+package diff
+
+// Au-delà de ce nombre d'occurrences, une ligne n'est pas utilisée comme ancre par histogram
+const maxChainLength = 64
+
+/**
+ * Diff histogram : cherche la suite de lignes communes contenant les lignes les moins fréquentes
+ * de l'ancienne version (à égalité, la plus longue), l'aligne, puis traite récursivement
+ * les parties situées avant et après
+ * Si toutes les lignes communes sont trop fréquentes, on revient à Myers
+ */
+func histogram(a, b []string) []Edit {
+	if len(a) == 0 || len(b) == 0 {
+		return replaceAll(a, b)
+	}
+
+	occurrences := make(map[string][]int)
+	for i, line := range a {
+		occurrences[line] = append(occurrences[line], i)
+	}
+
+	found := false
+	bestCount := maxChainLength + 1
+	var oldStart, oldEnd, newStart, newEnd int
+	for j := 0; j < len(b); {
+		next := j + 1
+		positions := occurrences[b[j]]
+		if len(positions) > bestCount {
+			j = next
+			continue
+		}
+		for _, i := range positions {
+			// Étendre la correspondance dans les deux sens
+			as, bs, ae, be := i, j, i+1, j+1
+			for as > 0 && bs > 0 && a[as-1] == b[bs-1] {
+				as--
+				bs--
+			}
+			for ae < len(a) && be < len(b) && a[ae] == b[be] {
+				ae++
+				be++
+			}
+
+			count := len(positions)
+			for k := as; k < ae; k++ {
+				count = min(count, len(occurrences[a[k]]))
+			}
+			if count < bestCount || (count == bestCount && ae-as > oldEnd-oldStart) {
+				found = true
+				bestCount = count
+				oldStart, oldEnd, newStart, newEnd = as, ae, bs, be
+			}
+			next = max(next, be)
+		}
+		j = next
+	}
+
+	if !found {
+		return boundedMyers(a, b)
+	}
+
+	edits := trimCommon(a[:oldStart], b[:newStart], histogram)
+	for k := 0; k < oldEnd-oldStart; k++ {
+		edits = append(edits, Edit{Op: Equal, OldIndex: oldStart + k, NewIndex: newStart + k, Line: a[oldStart+k]})
+	}
+	tail := trimCommon(a[oldEnd:], b[newEnd:], histogram)
+	return append(edits, shift(tail, oldEnd, newEnd)...)
+}
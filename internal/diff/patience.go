@@ -0,0 +1,104 @@
+package diff
+
+import "sort"
+
+/**
+ * Diff patience : les lignes présentes une seule fois dans chaque version servent d'ancres
+ * La plus longue suite d'ancres dans le même ordre des deux côtés est conservée,
+ * puis chaque intervalle entre deux ancres est traité récursivement
+ * Sans ligne unique commune, on revient à Myers
+ */
+func patience(a, b []string) []Edit {
+	if len(a) == 0 || len(b) == 0 {
+		return replaceAll(a, b)
+	}
+
+	anchors := longestIncreasing(uniqueCommon(a, b))
+	if len(anchors) == 0 {
+		return boundedMyers(a, b)
+	}
+
+	var edits []Edit
+	oldStart, newStart := 0, 0
+	for _, anchor := range anchors {
+		segment := trimCommon(a[oldStart:anchor.old], b[newStart:anchor.new], patience)
+		edits = append(edits, shift(segment, oldStart, newStart)...)
+		edits = append(edits, Edit{Op: Equal, OldIndex: anchor.old, NewIndex: anchor.new, Line: a[anchor.old]})
+		oldStart, newStart = anchor.old+1, anchor.new+1
+	}
+	segment := trimCommon(a[oldStart:], b[newStart:], patience)
+	return append(edits, shift(segment, oldStart, newStart)...)
+}
+
+/**
+ * Une paire de lignes identiques : indice dans l'ancienne et dans la nouvelle version
+ */
+type match struct {
+	old int
+	new int
+}
+
+/**
+ * Lignes qui apparaissent exactement une fois dans a et une fois dans b, triées par position dans a
+ */
+func uniqueCommon(a, b []string) []match {
+	type occurrence struct {
+		oldCount, newCount int
+		old, new           int
+	}
+	lines := make(map[string]*occurrence)
+	for i, line := range a {
+		occ, ok := lines[line]
+		if !ok {
+			occ = &occurrence{}
+			lines[line] = occ
+		}
+		occ.oldCount++
+		occ.old = i
+	}
+	for j, line := range b {
+		if occ, ok := lines[line]; ok {
+			occ.newCount++
+			occ.new = j
+		}
+	}
+
+	var matches []match
+	for _, occ := range lines {
+		if occ.oldCount == 1 && occ.newCount == 1 {
+			matches = append(matches, match{old: occ.old, new: occ.new})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].old < matches[j].old })
+	return matches
+}
+
+/**
+ * Plus longue sous-suite de matches croissante dans b (tri par piles de patience)
+ */
+func longestIncreasing(matches []match) []match {
+	// piles[k] : indice du match qui termine la meilleure suite de longueur k+1
+	var piles []int
+	previous := make([]int, len(matches))
+	for i, m := range matches {
+		k := sort.Search(len(piles), func(k int) bool { return matches[piles[k]].new > m.new })
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = piles[k-1]
+		}
+		if k == len(piles) {
+			piles = append(piles, i)
+		} else {
+			piles[k] = i
+		}
+	}
+	if len(piles) == 0 {
+		return nil
+	}
+
+	result := make([]match, len(piles))
+	for i, k := piles[len(piles)-1], len(piles)-1; k >= 0; i, k = previous[i], k-1 {
+		result[k] = matches[i]
+	}
+	return result
+}
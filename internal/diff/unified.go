@@ -0,0 +1,116 @@
+package diff
+
+import "fmt"
+
+// Nombre de lignes de contexte par défaut autour d'une modification
+const DefaultContext = 3
+
+/**
+ * Un bloc (hunk) d'un diff unifié : les modifications proches et leurs lignes de contexte
+ * OldStart et NewStart commencent à 1 (0 pour une version vide)
+ */
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Edits    []Edit
+}
+
+/**
+ * En-tête du bloc au format "@@ -a,b +c,d @@"
+ */
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+/**
+ * Plage d'un en-tête de bloc : le nombre de lignes est omis quand il vaut 1
+ */
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+/**
+ * Regroupe un script d'édition en blocs avec context lignes de contexte
+ * Deux modifications séparées par au plus 2*context lignes identiques sont dans le même bloc
+ */
+func Hunks(edits []Edit, context int) []Hunk {
+	var hunks []Hunk
+
+	i := 0
+	for i < len(edits) {
+		// Chercher la prochaine modification
+		for i < len(edits) && edits[i].Op == Equal {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := max(0, i-context)
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			// Lignes identiques : fin du bloc si elles sont trop nombreuses avant la modification suivante
+			next := end
+			for next < len(edits) && edits[next].Op == Equal {
+				next++
+			}
+			if next == len(edits) || next-end > 2*context {
+				end = min(next, end+context)
+				break
+			}
+			end = next
+		}
+
+		hunks = append(hunks, newHunk(edits, start, end))
+		i = end
+	}
+
+	return hunks
+}
+
+/**
+ * Construit un bloc à partir des éditions edits[start:end] et calcule ses plages
+ */
+func newHunk(edits []Edit, start, end int) Hunk {
+	h := Hunk{Edits: edits[start:end]}
+
+	// Lignes de chaque version qui précèdent le bloc
+	oldBefore, newBefore := 0, 0
+	for _, e := range edits[:start] {
+		if e.Op != Insert {
+			oldBefore++
+		}
+		if e.Op != Delete {
+			newBefore++
+		}
+	}
+
+	for _, e := range h.Edits {
+		if e.Op != Insert {
+			h.OldLines++
+		}
+		if e.Op != Delete {
+			h.NewLines++
+		}
+	}
+
+	// Une plage vide désigne la ligne qui précède
+	h.OldStart = oldBefore + 1
+	if h.OldLines == 0 {
+		h.OldStart = oldBefore
+	}
+	h.NewStart = newBefore + 1
+	if h.NewLines == 0 {
+		h.NewStart = newBefore
+	}
+	return h
+}
@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Par défaut, un mot est une suite de caractères qui ne sont pas des espaces
+var DefaultWordRegex = regexp.MustCompile(`\S+`)
+
+/**
+ * Un morceau de texte d'un diff par mots : commun aux deux versions, supprimé ou ajouté
+ */
+type WordSegment struct {
+	Op   Op
+	Text string
+}
+
+/**
+ * Position d'un mot dans son texte
+ */
+type word struct {
+	start int
+	end   int
+}
+
+/**
+ * Compare deux textes mot à mot : les mots sont les correspondances de wordRegex,
+ * le reste (espaces, retours à la ligne) n'est pas comparé et vient de la nouvelle version
+ * Les mots supprimés et ajoutés consécutifs sont regroupés avec les espaces qui les séparent
+ * Avec une expression comme "." on obtient un diff caractère par caractère
+ */
+func Words(oldText, newText string, wordRegex *regexp.Regexp, algorithm Algorithm) []WordSegment {
+	oldWords, oldTokens := splitWords(oldText, wordRegex)
+	newWords, newTokens := splitWords(newText, wordRegex)
+	edits := algorithm.Diff(oldTokens, newTokens)
+
+	var segments []WordSegment
+	add := func(op Op, text string) {
+		if text == "" {
+			return
+		}
+		if last := len(segments) - 1; last >= 0 && segments[last].Op == op {
+			segments[last].Text += text
+			return
+		}
+		segments = append(segments, WordSegment{Op: op, Text: text})
+	}
+
+	pos := 0
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			w := newWords[edits[i].NewIndex]
+			add(Equal, newText[pos:w.start])
+			add(Equal, newText[w.start:w.end])
+			pos = w.end
+			i++
+			continue
+		}
+
+		// Bloc de modifications : mots supprimés puis mots ajoutés
+		firstOld, lastOld, firstNew, lastNew := -1, -1, -1, -1
+		for ; i < len(edits) && edits[i].Op != Equal; i++ {
+			if edits[i].Op == Delete {
+				if firstOld < 0 {
+					firstOld = edits[i].OldIndex
+				}
+				lastOld = edits[i].OldIndex
+			} else {
+				if firstNew < 0 {
+					firstNew = edits[i].NewIndex
+				}
+				lastNew = edits[i].NewIndex
+			}
+		}
+		if firstNew >= 0 {
+			add(Equal, newText[pos:newWords[firstNew].start])
+		}
+		if firstOld >= 0 {
+			oldEnd := oldWords[lastOld].end
+			if firstNew < 0 {
+				oldEnd += deletedLineBreak(oldText, oldWords, lastOld, newText, newWords, pos, edits[i:])
+			}
+			add(Delete, oldText[oldWords[firstOld].start:oldEnd])
+		}
+		if firstNew >= 0 {
+			add(Insert, newText[newWords[firstNew].start:newWords[lastNew].end])
+			pos = newWords[lastNew].end
+		}
+	}
+	add(Equal, newText[pos:])
+
+	return segments
+}
+
+/**
+ * Pour un bloc sans mot ajouté : longueur des espaces qui suivaient le dernier mot supprimé
+ * jusqu'à la fin de ligne incluse, si la nouvelle version n'a pas de fin de ligne à cet endroit
+ * Sans cela, la ligne suivante serait collée aux mots supprimés
+ */
+func deletedLineBreak(oldText string, oldWords []word, lastOld int, newText string, newWords []word, pos int, rest []Edit) int {
+	oldEnd, oldNext := oldWords[lastOld].end, len(oldText)
+	if lastOld+1 < len(oldWords) {
+		oldNext = oldWords[lastOld+1].start
+	}
+	lineBreak := strings.IndexByte(oldText[oldEnd:oldNext], '\n')
+	if lineBreak < 0 {
+		return 0
+	}
+
+	newNext := len(newText)
+	if len(rest) > 0 {
+		newNext = newWords[rest[0].NewIndex].start
+	}
+	if strings.Contains(newText[pos:newNext], "\n") {
+		return 0
+	}
+	return lineBreak + 1
+}
+
+/**
+ * Positions et contenus des mots d'un texte
+ */
+func splitWords(text string, wordRegex *regexp.Regexp) ([]word, []string) {
+	var words []word
+	var tokens []string
+	for _, match := range wordRegex.FindAllStringIndex(text, -1) {
+		if match[0] == match[1] {
+			continue
+		}
+		words = append(words, word{start: match[0], end: match[1]})
+		tokens = append(tokens, text[match[0]:match[1]])
+	}
+	return words, tokens
+}
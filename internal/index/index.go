@@ -1,37 +1,59 @@
 package index
 
 import (
-	"crypto/sha1"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"projet-go-git/internal/objects"
+	"sort"
 	"strings"
 )
 
 type IndexEntry struct {
+	Mode     string
 	Hash     string
 	Filename string
+	Stage    int
 }
 
 /**
- * Vérifie si un merge est en cours
+ * Étapes (stages) d'un chemin en conflit pendant un merge
+ * Un chemin résolu n'a qu'une entrée à l'étape 0
  */
-func isMergeInProgress() bool {
-	_, err := os.Stat(filepath.Join(".goit", "MERGE_HEAD"))
-	return err == nil
+const (
+	StageResolved = 0
+	StageBase     = 1
+	StageOurs     = 2
+	StageTheirs   = 3
+)
+
+/**
+ * Versions d'un chemin en conflit : ancêtre commun, branche actuelle, branche fusionnée
+ * Une version absente (fichier ajouté d'un seul côté, supprimé d'un côté...) vaut nil
+ */
+type Conflict struct {
+	Base   *objects.FileEntry
+	Ours   *objects.FileEntry
+	Theirs *objects.FileEntry
+}
+
+/**
+ * Versions d'un conflit indexées par étape
+ */
+func (c Conflict) stages() map[int]*objects.FileEntry {
+	return map[int]*objects.FileEntry{StageBase: c.Base, StageOurs: c.Ours, StageTheirs: c.Theirs}
 }
 
 /**
  * Ajoute un fichier à l'index seulement s'il a changé ou n'est pas suivi
  * Retourne true si le fichier a été ajouté, false sinon
  */
-func addSingleFile(filename string, indexEntries map[string]string) (bool, error) {
+func addSingleFile(filename string, indexEntries map[string]objects.FileEntry, conflicts map[string]Conflict) (bool, error) {
 	if strings.HasPrefix(filename, ".goit") {
 		return false, nil
 	}
 
-	fileInfo, err := os.Stat(filename)
+	fileInfo, err := os.Lstat(filename)
 	if err != nil {
 		return false, fmt.Errorf("error accessing file %s: %v", filename, err)
 	}
@@ -40,114 +62,166 @@ func addSingleFile(filename string, indexEntries map[string]string) (bool, error
 		return false, nil
 	}
 
-	// Calculer le hash actuel du fichier
-	hash, content, err := processFile(filename)
+	// Calculer le hash et le mode actuels du fichier
+	entry, content, err := objects.ReadWorkingFile(filename)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("error reading file %s: %v", filename, err)
 	}
 
-	// Cela permet de résoudre les conflits même si le fichier semble inchangé
-	if isMergeInProgress() {
-		// Forcer l'ajout pendant un merge
-		objectPath := filepath.Join(".goit", "objects", hash)
-		if err := os.WriteFile(objectPath, content, 0644); err != nil {
-			return false, fmt.Errorf("error storing object %s: %v", objectPath, err)
+	// Ajouter un chemin en conflit le marque comme résolu : ses étapes sont remplacées par l'étape 0
+	if _, conflicted := conflicts[filename]; conflicted {
+		if _, err := objects.WriteBlob(content); err != nil {
+			return false, fmt.Errorf("error storing %s: %v", filename, err)
 		}
-		indexEntries[filename] = hash
+		delete(conflicts, filename)
+		indexEntries[filename] = entry
 		return true, nil
 	}
 
 	// Vérifier si le fichier a changé par rapport à l'index
-	currentHash, existsInIndex := indexEntries[filename]
-	if existsInIndex && currentHash == hash {
+	// L'index contient l'instantané complet du prochain commit
+	current, existsInIndex := indexEntries[filename]
+	if existsInIndex && current == entry {
 		// Le fichier n'a pas changé par rapport à l'index, ne pas l'ajouter
 		return false, nil
 	}
 
-	// Vérifier si le fichier a changé par rapport au dernier commit
-	lastCommitHash := getLastCommitHash(filename)
-	if lastCommitHash != "" && lastCommitHash == hash {
-		// Le fichier n'a pas changé par rapport au dernier commit, ne pas l'ajouter
-		return false, nil
-	}
-
-	// Le fichier a changé ou n'existe pas dans l'index/commit, l'ajouter
-	objectPath := filepath.Join(".goit", "objects", hash)
-	if err := os.WriteFile(objectPath, content, 0644); err != nil {
-		return false, fmt.Errorf("error storing object %s: %v", objectPath, err)
+	// Le fichier a changé ou n'existe pas dans l'index, l'ajouter
+	if _, err := objects.WriteBlob(content); err != nil {
+		return false, fmt.Errorf("error storing %s: %v", filename, err)
 	}
 
-	indexEntries[filename] = hash
+	indexEntries[filename] = entry
 	return true, nil
 }
 
-// ... reste du code inchangé ...
-
 /**
- * Traite un fichier pour calculer son hash et récupérer son contenu
+ * Charge les entrées résolues (étape 0) de l'index depuis le fichier
+ * Si l'index n'existe pas, il est initialisé avec l'arbre du commit HEAD
  */
-func processFile(filename string) (string, []byte, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return "", nil, fmt.Errorf("error opening file %s: %v", filename, err)
-	}
-	defer f.Close()
-
-	h := sha1.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", nil, fmt.Errorf("error calculating hash for %s: %v", filename, err)
-	}
-	hash := fmt.Sprintf("%x", h.Sum(nil))
-
-	if _, err := f.Seek(0, 0); err != nil {
-		return "", nil, fmt.Errorf("error seeking in file %s: %v", filename, err)
-	}
-
-	content, err := io.ReadAll(f)
-	if err != nil {
-		return "", nil, fmt.Errorf("error reading content of %s: %v", filename, err)
-	}
-
-	return hash, content, nil
+func loadIndexEntries() (map[string]objects.FileEntry, error) {
+	indexEntries, _, err := loadIndex()
+	return indexEntries, err
 }
 
 /**
- * Charge les entrées de l'index depuis le fichier
+ * Charge les entrées résolues et les chemins en conflit de l'index
  */
-func loadIndexEntries() (map[string]string, error) {
-	indexEntries := make(map[string]string)
+func loadIndex() (map[string]objects.FileEntry, map[string]Conflict, error) {
 	indexPath := filepath.Join(".goit", "index")
 
 	indexContent, err := os.ReadFile(indexPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return indexEntries, nil
+			return getHeadTreeFiles(), make(map[string]Conflict), nil
 		}
-		return nil, fmt.Errorf("error reading index: %v", err)
+		return nil, nil, fmt.Errorf("error reading index: %v", err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(indexContent)), "\n")
+	indexEntries, conflicts := parseIndex(string(indexContent))
+	return indexEntries, conflicts, nil
+}
+
+/**
+ * Parse le contenu du fichier index
+ * Format : "<mode> <hash>\t<fichier>" pour une entrée résolue,
+ * "<mode> <hash> <étape>\t<fichier>" pour une version d'un chemin en conflit ;
+ * l'ancien format "<hash> <fichier>" est encore accepté
+ */
+func parseIndex(content string) (map[string]objects.FileEntry, map[string]Conflict) {
+	indexEntries := make(map[string]objects.FileEntry)
+	conflicts := make(map[string]Conflict)
+
+	lines := strings.Split(strings.TrimSpace(content), "\n")
 	for _, line := range lines {
 		if line == "" {
 			continue
 		}
+		if meta, file, ok := strings.Cut(line, "\t"); ok {
+			fields := strings.Fields(meta)
+			switch len(fields) {
+			case 2:
+				indexEntries[file] = objects.FileEntry{Mode: fields[0], Hash: fields[1]}
+			case 3:
+				entry := &objects.FileEntry{Mode: fields[0], Hash: fields[1]}
+				conflict := conflicts[file]
+				switch fields[2] {
+				case "1":
+					conflict.Base = entry
+				case "2":
+					conflict.Ours = entry
+				case "3":
+					conflict.Theirs = entry
+				}
+				conflicts[file] = conflict
+			}
+			continue
+		}
 		parts := strings.SplitN(line, " ", 2)
 		if len(parts) == 2 {
 			hash, file := parts[0], parts[1]
-			indexEntries[file] = hash
+			indexEntries[file] = objects.FileEntry{Mode: objects.ModeFile, Hash: hash}
 		}
 	}
 
-	return indexEntries, nil
+	return indexEntries, conflicts
 }
 
 /**
- * Écrit les entrées de l'index dans le fichier
+ * Normalise des chemins donnés en ligne de commande pour MatchesPathspec
  */
-func writeIndexEntries(indexEntries map[string]string) error {
+func CleanPathspecs(paths []string) []string {
+	pathspecs := make([]string, len(paths))
+	for i, path := range paths {
+		pathspecs[i] = filepath.ToSlash(filepath.Clean(path))
+	}
+	return pathspecs
+}
+
+/**
+ * Vérifie si un chemin suivi correspond à un des chemins demandés
+ * Un répertoire correspond à tous les fichiers qu'il contient, "." à tous les fichiers
+ */
+func MatchesPathspec(path string, pathspecs []string) bool {
+	for _, spec := range pathspecs {
+		if spec == "." || path == spec || strings.HasPrefix(path, spec+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Retourne les chemins de l'index triés (ordre des octets)
+ */
+func sortedPaths(indexEntries map[string]objects.FileEntry) []string {
+	paths := make([]string, 0, len(indexEntries))
+	for path := range indexEntries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+/**
+ * Écrit les entrées de l'index dans le fichier, triées par chemin
+ * Un même ensemble de fichiers produit donc toujours le même index
+ */
+func writeIndexEntries(indexEntries map[string]objects.FileEntry) error {
+	return writeIndex(indexEntries, nil)
+}
+
+/**
+ * Écrit les entrées résolues et les étapes des chemins en conflit
+ */
+func writeIndex(indexEntries map[string]objects.FileEntry, conflicts map[string]Conflict) error {
 	var lines []string
-	for file, hash := range indexEntries {
-		lines = append(lines, fmt.Sprintf("%s %s", hash, file))
+	for _, entry := range listEntries(indexEntries, conflicts) {
+		if entry.Stage == StageResolved {
+			lines = append(lines, fmt.Sprintf("%s %s\t%s", entry.Mode, entry.Hash, entry.Filename))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s %s %d\t%s", entry.Mode, entry.Hash, entry.Stage, entry.Filename))
+		}
 	}
 
 	content := strings.Join(lines, "\n")
@@ -160,13 +234,13 @@ func writeIndexEntries(indexEntries map[string]string) error {
 }
 
 /**
- * Récupère le hash d'un fichier depuis le dernier commit
+ * Récupère les fichiers de l'arbre du commit HEAD
  */
-func getLastCommitHash(filename string) string {
+func getHeadTreeFiles() map[string]objects.FileEntry {
 	// Lire HEAD
 	head, err := os.ReadFile(".goit/HEAD")
 	if err != nil {
-		return ""
+		return make(map[string]objects.FileEntry)
 	}
 
 	headContent := strings.TrimSpace(string(head))
@@ -183,52 +257,33 @@ func getLastCommitHash(filename string) string {
 	}
 
 	if commitHash == "" {
-		return ""
-	}
-
-	// Lire le commit
-	commitPath := filepath.Join(".goit", "objects", commitHash)
-	commitData, err := os.ReadFile(commitPath)
-	if err != nil {
-		return ""
-	}
-
-	// Extraire le tree hash
-	lines := strings.Split(string(commitData), "\n")
-	var treeHash string
-	for _, line := range lines {
-		if strings.HasPrefix(line, " tree ") {
-			treeHash = strings.TrimPrefix(line, " tree ")
-			break
-		}
-	}
-
-	if treeHash == "" {
-		return ""
+		return make(map[string]objects.FileEntry)
 	}
 
-	// Lire le tree
-	treePath := filepath.Join(".goit", "objects", treeHash)
-	treeData, err := os.ReadFile(treePath)
+	files, err := objects.ReadCommitFiles(commitHash)
 	if err != nil {
-		return ""
-	}
-
-	// Parser le tree pour trouver le fichier
-	treeLines := strings.Split(string(treeData), "\n")
-	for _, line := range treeLines {
-		if line != "" && !strings.HasPrefix(line, "tree") {
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				hash, file := parts[0], parts[1]
-				if file == filename {
-					return hash
-				}
-			}
-		}
+		return make(map[string]objects.FileEntry)
 	}
+	return files
+}
 
-	return ""
+/**
+ * Retire de l'index un fichier suivi qui a été supprimé du répertoire de travail
+ * Retourne true si l'entrée a été retirée
+ */
+func removeDeletedFile(filename string, indexEntries map[string]objects.FileEntry, conflicts map[string]Conflict) bool {
+	_, exists := indexEntries[filename]
+	_, conflicted := conflicts[filename]
+	if !exists && !conflicted {
+		return false
+	}
+	if _, err := os.Lstat(filename); err == nil {
+		return false
+	}
+	// Un chemin en conflit supprimé du répertoire de travail est résolu par sa suppression
+	delete(indexEntries, filename)
+	delete(conflicts, filename)
+	return true
 }
 
 /**
@@ -237,7 +292,7 @@ func getLastCommitHash(filename string) string {
  * Sinon, ajoute le fichier spécifié
  */
 func Add(filename string) {
-	indexEntries, err := loadIndexEntries()
+	indexEntries, conflicts, err := loadIndex()
 	if err != nil {
 		fmt.Printf("Error loading index: %v\n", err)
 		return
@@ -256,7 +311,7 @@ func Add(filename string) {
 				return nil
 			}
 
-			wasAdded, err := addSingleFile(path, indexEntries)
+			wasAdded, err := addSingleFile(path, indexEntries, conflicts)
 			if err != nil {
 				fmt.Printf("Warning: %v\n", err)
 			} else if wasAdded {
@@ -271,73 +326,177 @@ func Add(filename string) {
 			return
 		}
 
+		// Retirer de l'index les fichiers suivis (ou en conflit) qui ont été supprimés
+		tracked := make(map[string]objects.FileEntry, len(indexEntries)+len(conflicts))
+		for file, entry := range indexEntries {
+			tracked[file] = entry
+		}
+		for file := range conflicts {
+			tracked[file] = objects.FileEntry{}
+		}
+		for _, file := range sortedPaths(tracked) {
+			if removeDeletedFile(file, indexEntries, conflicts) {
+				fmt.Printf("Removed %s\n", file)
+				addedCount++
+			}
+		}
+
 		if addedCount == 0 {
 			fmt.Println("No files were added")
 			return
 		}
 	} else {
 		if _, err := os.Stat(filename); os.IsNotExist(err) {
-			fmt.Printf("pathspec '%s' did not match any files\n", filename)
+			if !removeDeletedFile(filename, indexEntries, conflicts) {
+				fmt.Printf("pathspec '%s' did not match any files\n", filename)
+				return
+			}
+			fmt.Printf("Removed %s\n", filename)
+			if err := writeIndex(indexEntries, conflicts); err != nil {
+				fmt.Printf("Error writing index: %v\n", err)
+			}
 			return
 		}
 
-		wasAdded, err := addSingleFile(filename, indexEntries)
+		_, conflicted := conflicts[filename]
+		wasAdded, err := addSingleFile(filename, indexEntries, conflicts)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			return
 		}
-		if wasAdded {
+		switch {
+		case conflicted:
+			fmt.Printf("Added %s (merge resolution)\n", filename)
+		case wasAdded:
 			fmt.Printf("Added %s\n", filename)
-		} else {
-			if isMergeInProgress() {
-				fmt.Printf("Added %s (merge resolution)\n", filename)
-			} else {
-				fmt.Printf("No changes detected for %s\n", filename)
-			}
+		default:
+			fmt.Printf("No changes detected for %s\n", filename)
 		}
 	}
 
-	if err := writeIndexEntries(indexEntries); err != nil {
+	if err := writeIndex(indexEntries, conflicts); err != nil {
 		fmt.Printf("Error writing index: %v\n", err)
 	}
 }
 
 /**
- * Récupère toutes les entrées de l'index
+ * Remplace le contenu de l'index par les fichiers donnés (nom -> hash)
+ * Utilisée après un checkout ou un merge pour aligner l'index sur un commit
  */
-func GetIndexEntries() ([]IndexEntry, error) {
-	indexEntries, err := loadIndexEntries()
+func WriteEntries(files map[string]objects.FileEntry) error {
+	return writeIndexEntries(files)
+}
+
+/**
+ * Remplace le contenu de l'index par des fichiers résolus et des chemins en conflit
+ * Utilisée par un merge en conflit, ou pour modifier l'index sans perdre les conflits restants
+ */
+func WriteEntriesWithConflicts(files map[string]objects.FileEntry, conflicts map[string]Conflict) error {
+	return writeIndex(files, conflicts)
+}
+
+/**
+ * Récupère les chemins en conflit de l'index (chemin -> versions)
+ */
+func ReadConflicts() (map[string]Conflict, error) {
+	_, conflicts, err := loadIndex()
+	return conflicts, err
+}
+
+/**
+ * Liste triée des chemins encore en conflit
+ */
+func UnmergedPaths() ([]string, error) {
+	conflicts, err := ReadConflicts()
 	if err != nil {
 		return nil, err
 	}
+	paths := make([]string, 0, len(conflicts))
+	for path := range conflicts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
 
-	var entries []IndexEntry
-	for filename, hash := range indexEntries {
-		entries = append(entries, IndexEntry{
-			Hash:     hash,
-			Filename: filename,
-		})
+/**
+ * Crée l'index à partir de l'arbre du commit HEAD s'il n'existe pas encore
+ */
+func EnsureIndex() error {
+	indexPath := filepath.Join(".goit", "index")
+	if _, err := os.Stat(indexPath); err == nil {
+		return nil
 	}
+	return writeIndexEntries(getHeadTreeFiles())
+}
 
-	return entries, nil
+/**
+ * Récupère les fichiers de l'index (chemin -> entrée)
+ */
+func ReadFiles() (map[string]objects.FileEntry, error) {
+	return loadIndexEntries()
 }
 
 /**
- * Supprime les doublons et synchronise avec le dernier commit
+ * Vérifie si l'index diffère de l'arbre du commit HEAD
  */
-func CleanupAfterMerge() {
+func HasStagedChanges() (bool, error) {
 	indexEntries, err := loadIndexEntries()
 	if err != nil {
-		return
+		return false, err
+	}
+
+	headFiles := getHeadTreeFiles()
+	if len(headFiles) != len(indexEntries) {
+		return true, nil
+	}
+	for filename, entry := range indexEntries {
+		if headFiles[filename] != entry {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/**
+ * Récupère toutes les entrées de l'index, triées par chemin puis par étape
+ * Les chemins en conflit apparaissent avec une entrée par version (étapes 1 à 3)
+ */
+func GetIndexEntries() ([]IndexEntry, error) {
+	indexEntries, conflicts, err := loadIndex()
+	if err != nil {
+		return nil, err
 	}
+	return listEntries(indexEntries, conflicts), nil
+}
 
-	// Nettoyer les doublons
-	cleanedEntries := make(map[string]string)
-	for filename, hash := range indexEntries {
-		cleanedEntries[filename] = hash
+/**
+ * Met à plat les entrées résolues et les étapes des conflits, triées par chemin puis par étape
+ * Un chemin en conflit n'a pas d'entrée à l'étape 0
+ */
+func listEntries(indexEntries map[string]objects.FileEntry, conflicts map[string]Conflict) []IndexEntry {
+	all := make(map[string]objects.FileEntry, len(indexEntries)+len(conflicts))
+	for filename, entry := range indexEntries {
+		all[filename] = entry
+	}
+	for filename := range conflicts {
+		all[filename] = objects.FileEntry{}
 	}
 
-	if err := writeIndexEntries(cleanedEntries); err != nil {
-		fmt.Printf("Error cleaning up index: %v\n", err)
+	var entries []IndexEntry
+	for _, filename := range sortedPaths(all) {
+		conflict, conflicted := conflicts[filename]
+		if !conflicted {
+			entry := all[filename]
+			entries = append(entries, IndexEntry{Mode: entry.Mode, Hash: entry.Hash, Filename: filename})
+			continue
+		}
+		stages := conflict.stages()
+		for _, stage := range []int{StageBase, StageOurs, StageTheirs} {
+			if entry := stages[stage]; entry != nil {
+				entries = append(entries, IndexEntry{Mode: entry.Mode, Hash: entry.Hash, Filename: filename, Stage: stage})
+			}
+		}
 	}
+	return entries
 }
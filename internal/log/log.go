@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"projet-go-git/internal/objects"
+	"projet-go-git/internal/repository"
+	"projet-go-git/internal/status"
 	"strings"
-	"time"
 )
 
 const (
@@ -18,10 +20,12 @@ const (
 )
 
 type CommitInfo struct {
-	Hash    string
-	Message string
-	Date    string
-	Refs    []string
+	Hash      string
+	Message   string
+	Author    string
+	Committer string
+	Date      string
+	Refs      []string
 }
 
 /**
@@ -45,8 +49,8 @@ func getCurrentBranch() string {
 }
 
 /**
- * Trouve toutes les références (branches + HEAD) pointant vers un hash donné
- * Retourne une liste des noms de branches et "HEAD" si applicable
+ * Trouve toutes les références (branches, tags + HEAD) pointant vers un hash donné
+ * Retourne une liste des noms de branches, des tags ("tag: nom") et "HEAD" si applicable
  * Utilisée par ShowLog() et ShowLogShort() pour afficher les références
  */
 func getRefsForHash(targetHash string) []string {
@@ -67,6 +71,20 @@ func getRefsForHash(targetHash string) []string {
 		}
 	}
 
+	tagsDir := ".goit/refs/tags"
+	if entries, err := os.ReadDir(tagsDir); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if content, err := os.ReadFile(filepath.Join(tagsDir, entry.Name())); err == nil {
+				if strings.TrimSpace(string(content)) == targetHash {
+					refs = append(refs, "tag: "+entry.Name())
+				}
+			}
+		}
+	}
+
 	if head, err := os.ReadFile(".goit/HEAD"); err == nil {
 		headContent := strings.TrimSpace(string(head))
 		if strings.HasPrefix(headContent, "ref: ") {
@@ -90,6 +108,7 @@ func getRefsForHash(targetHash string) []string {
  * Formate les références avec des couleurs pour l'affichage
  * - HEAD et branche courante : bleu et gras
  * - Autres branches : rose
+ * - Tags : jaune et gras
  * - withParentheses : ajoute des parenthèses autour des refs
  * Utilisée par displayDetailedCommit() et displayCompactCommit()
  */
@@ -116,7 +135,9 @@ func formatRefsWithColors(refs []string, withParentheses bool) string {
 	}
 
 	for _, ref := range localRefs {
-		if ref == currentBranch {
+		if strings.HasPrefix(ref, "tag: ") {
+			result = append(result, colorYellow+colorBold+ref+colorReset)
+		} else if ref == currentBranch {
 			result = append(result, colorBlue+colorBold+ref+colorReset)
 		} else {
 			result = append(result, colorPink+ref+colorReset)
@@ -155,144 +176,180 @@ func getCommitHash() string {
 }
 
 /**
- * Parse les données brutes d'un commit pour extraire les informations
- * Extrait le message, la date et ignore les métadonnées (tree, parent, etc.)
+ * Extrait les informations à afficher d'un commit
+ * Formate la date, l'auteur et ne garde que la première ligne du message
+ * Le committer n'est renseigné que s'il diffère de l'auteur
  * Utilisée par ShowLog() et ShowLogShort() pour traiter les données de commit
  */
-func parseCommitData(data string) CommitInfo {
-	lines := strings.Split(data, "\n")
+func parseCommitData(commit *objects.Commit) CommitInfo {
 	var info CommitInfo
-	foundEmptyLine := false
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Parser la date
-		if strings.HasPrefix(line, "date ") {
-			dateStr := strings.TrimPrefix(line, "date ")
-			if t, err := time.Parse(time.RFC3339, dateStr); err == nil {
-				info.Date = t.Format("02/01/2006 15:04")
-			} else {
-				info.Date = dateStr
-			}
+
+	// Parser la date (avec le fuseau horaire de l'auteur si connu)
+	if t, ok := commit.Time(); !ok {
+		info.Date = commit.Date
+	} else if commit.Author != nil {
+		info.Date = t.Format("02/01/2006 15:04 -0700")
+	} else {
+		info.Date = t.Format("02/01/2006 15:04")
+	}
+
+	if commit.Author != nil {
+		info.Author = fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email)
+	}
+	if commit.Committer != nil {
+		committer := fmt.Sprintf("%s <%s>", commit.Committer.Name, commit.Committer.Email)
+		if committer != info.Author {
+			info.Committer = committer
 		}
+	}
 
-		// Détecter la ligne vide qui sépare les métadonnées du message
-		if line == "" {
-			foundEmptyLine = true
+	for _, line := range strings.Split(commit.Message, "\n") {
+		message := strings.TrimSpace(line)
+		if message == "" {
 			continue
 		}
-
-		// Après la ligne vide, tout est considéré comme le message
-		if foundEmptyLine && line != "" {
-			// Nettoyer le message des caractères bizarres
-			message := strings.TrimSpace(line)
-			// Supprimer les caractères non imprimables
-			var cleanMessage strings.Builder
-			for _, r := range message {
-				if r >= 32 && r != 127 { // Caractères imprimables
-					cleanMessage.WriteRune(r)
-				}
+		// Supprimer les caractères non imprimables
+		var cleanMessage strings.Builder
+		for _, r := range message {
+			if r >= 32 && r != 127 { // Caractères imprimables
+				cleanMessage.WriteRune(r)
 			}
-			info.Message = strings.TrimSpace(cleanMessage.String())
-			break
 		}
+		info.Message = strings.TrimSpace(cleanMessage.String())
+		break
 	}
 
 	return info
 }
 
 /**
- * Extrait le hash du commit parent depuis les données du commit
- * Retourne une chaîne vide si aucun parent n'est trouvé
- * Utilisée par ShowLog() et ShowLogShort() pour naviguer dans l'historique
+ * Liste les commits à afficher pour une révision ou une plage (A..B, A...B)
+ * Sans révision, l'historique part de HEAD
  */
-func extractParentHash(content string) string {
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "parent ") {
-			return strings.TrimPrefix(line, "parent ")
+func listCommits(rev string) ([]string, error) {
+	if rev == "" {
+		if getCommitHash() == "" {
+			return nil, nil
 		}
+		rev = "HEAD"
 	}
-	return ""
+
+	include, exclude, err := repository.ParseRange(rev)
+	if err != nil {
+		return nil, err
+	}
+	return repository.RevList(include, exclude)
 }
 
 /**
- * Lit le fichier d'objet commit depuis le disque
- * Helper function pour centraliser la lecture des objets commit
- * Utilisée par ShowLog() et ShowLogShort()
+ * Affiche l'historique détaillé des commits
+ * Parcourt tous les ancêtres (y compris le second parent des merges), du plus récent au plus ancien
+ * Affiche toutes les informations : hash, date, message, références
+ * diffOpts (format et algorithme) ajoute les changements de chaque commit, nil pour aucun
  */
-func readCommitObject(hash string) ([]byte, error) {
-	path := filepath.Join(".goit", "objects", hash)
-	return os.ReadFile(path)
+func ShowLog(rev string, diffOpts *status.DiffOptions) {
+	showCommits(rev, diffOpts, displayDetailedCommit)
 }
 
 /**
- * Affiche l'historique détaillé des commits
- * Parcourt la chaîne de commits en remontant vers les parents
- * Affiche toutes les informations : hash, date, message, références
+ * Affiche l'historique compact des commits
+ * Même logique que ShowLog() mais avec un affichage simplifié
+ * Affiche seulement le hash court, le message et les références
  */
-func ShowLog() {
-	hash := getCommitHash()
-	if hash == "" {
-		fmt.Println("No commits yet")
+func ShowLogShort(rev string, diffOpts *status.DiffOptions) {
+	showCommits(rev, diffOpts, displayCompactCommit)
+}
+
+/**
+ * Affiche un commit (HEAD par défaut) et les changements qu'il introduit
+ * Un merge est comparé à son premier parent
+ */
+func ShowCommit(rev string, diffOpts status.DiffOptions) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	hash, err := repository.ResolveRevision(rev)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	commit, err := objects.ReadCommit(hash)
+	if err != nil {
+		fmt.Println("Error reading commit object:", err)
 		return
 	}
 
-	for hash != "" {
-		data, err := readCommitObject(hash)
-		if err != nil {
-			fmt.Println("Error reading commit object:", err)
-			return
-		}
-
-		info := parseCommitData(string(data))
-		info.Hash = hash
-		info.Refs = getRefsForHash(hash)
+	info := parseCommitData(commit)
+	info.Hash = hash
+	info.Refs = getRefsForHash(hash)
+	displayDetailedCommit(info)
 
-		displayDetailedCommit(info)
-		hash = extractParentHash(string(data))
+	if err := showCommitDiff(hash, commit, diffOpts); err != nil {
+		fmt.Println("Error:", err)
 	}
 }
 
 /**
- * Affiche l'historique compact des commits
- * Même logique que ShowLog() mais avec un affichage simplifié
- * Affiche seulement le hash court, le message et les références
+ * Affiche les changements d'un commit par rapport à son premier parent (arbre vide pour le premier commit)
  */
-func ShowLogShort() {
-	hash := getCommitHash()
-	if hash == "" {
+func showCommitDiff(hash string, commit *objects.Commit, diffOpts status.DiffOptions) error {
+	parentHash := ""
+	if len(commit.Parents) > 0 {
+		parentHash = commit.Parents[0]
+	}
+	return status.ShowCommitDiff(parentHash, hash, diffOpts)
+}
+
+func showCommits(rev string, diffOpts *status.DiffOptions, display func(CommitInfo)) {
+	hashes, err := listCommits(rev)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if rev == "" && len(hashes) == 0 {
 		fmt.Println("No commits yet")
 		return
 	}
 
-	for hash != "" {
-		data, err := readCommitObject(hash)
+	for _, hash := range hashes {
+		commit, err := objects.ReadCommit(hash)
 		if err != nil {
 			fmt.Println("Error reading commit object:", err)
 			return
 		}
 
-		info := parseCommitData(string(data))
+		info := parseCommitData(commit)
 		info.Hash = hash
 		info.Refs = getRefsForHash(hash)
 
-		displayCompactCommit(info)
-		hash = extractParentHash(string(data))
+		display(info)
+
+		// Comme git log -p, les merges n'affichent pas de diff
+		if diffOpts != nil && len(commit.Parents) < 2 {
+			if err := showCommitDiff(hash, commit, *diffOpts); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			fmt.Println()
+		}
 	}
 }
 
 /**
  * Affiche un commit en format détaillé
- * Affiche toutes les informations : hash complet, date, message, références
+ * Affiche toutes les informations : hash complet, auteur, date, message, références
  * Utilise formatRefsWithColors() pour colorer les références
  */
 func displayDetailedCommit(info CommitInfo) {
 	refsStr := formatRefsWithColors(info.Refs, false)
 
 	fmt.Printf("%s●%s %sCommit: %s%s%s\n", colorYellow, colorReset, colorYellow, colorBold, info.Hash, colorReset)
+	if info.Author != "" {
+		fmt.Printf("%s|%s %sAuthor: %s%s\n", colorYellow, colorReset, colorWhite, info.Author, colorReset)
+	}
+	if info.Committer != "" {
+		fmt.Printf("%s|%s %sCommitter: %s%s\n", colorYellow, colorReset, colorWhite, info.Committer, colorReset)
+	}
 	fmt.Printf("%s|%s %sDate:   %s%s\n", colorYellow, colorReset, colorWhite, info.Date, colorReset)
 	fmt.Printf("%s|%s %sTitle:  %s%s\n", colorYellow, colorReset, colorWhite, info.Message, colorReset)
 	if refsStr != "" {
@@ -0,0 +1,162 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+
+	"projet-go-git/internal/diff"
+)
+
+/**
+ * Marqueurs entourant un bloc en conflit :
+ * "<conflictMarker> <branche actuelle>", la version actuelle, conflictSeparator,
+ * la version fusionnée puis "<conflictMarker> <branche fusionnée>"
+ */
+const (
+	conflictMarker    = "**************"
+	conflictSeparator = "========="
+)
+
+/**
+ * Résultat d'une fusion à trois voies ligne par ligne
+ */
+type mergeResult struct {
+	Content   string
+	Conflicts int
+}
+
+/**
+ * Fusionne trois versions d'un contenu (style diff3)
+ * Les blocs modifiés d'un seul côté sont appliqués automatiquement,
+ * seuls les blocs modifiés différemment des deux côtés sont entourés de marqueurs
+ */
+func mergeLines(base, ours, theirs string, oursLabel, theirsLabel string) mergeResult {
+	baseLines := diff.SplitLines(base)
+	oursLines := diff.SplitLines(ours)
+	theirsLines := diff.SplitLines(theirs)
+
+	matchOurs := matchingLines(baseLines, oursLines)
+	matchTheirs := matchingLines(baseLines, theirsLines)
+
+	var out strings.Builder
+	result := mergeResult{}
+	o, a, b := 0, 0, 0
+
+	for o < len(baseLines) || a < len(oursLines) || b < len(theirsLines) {
+		// Bloc stable : la ligne de base est alignée des deux côtés
+		stable := 0
+		for o+stable < len(baseLines) &&
+			matchOurs[o+stable] == a+stable &&
+			matchTheirs[o+stable] == b+stable {
+			stable++
+		}
+		if stable > 0 {
+			for _, line := range baseLines[o : o+stable] {
+				out.WriteString(line)
+			}
+			o += stable
+			a += stable
+			b += stable
+			continue
+		}
+
+		// Bloc instable : chercher la prochaine ligne de base alignée des deux côtés
+		nextO, nextA, nextB := len(baseLines), len(oursLines), len(theirsLines)
+		for i := o; i < len(baseLines); i++ {
+			if matchOurs[i] >= a && matchTheirs[i] >= b {
+				nextO, nextA, nextB = i, matchOurs[i], matchTheirs[i]
+				break
+			}
+		}
+
+		baseChunk := baseLines[o:nextO]
+		oursChunk := oursLines[a:nextA]
+		theirsChunk := theirsLines[b:nextB]
+
+		switch {
+		case sameLines(oursChunk, baseChunk):
+			writeLines(&out, theirsChunk)
+		case sameLines(theirsChunk, baseChunk), sameLines(oursChunk, theirsChunk):
+			writeLines(&out, oursChunk)
+		default:
+			result.Conflicts++
+			fmt.Fprintf(&out, "%s %s\n", conflictMarker, oursLabel)
+			writeLines(&out, oursChunk)
+			ensureNewline(&out, oursChunk)
+			out.WriteString(conflictSeparator + "\n")
+			writeLines(&out, theirsChunk)
+			ensureNewline(&out, theirsChunk)
+			fmt.Fprintf(&out, "%s %s\n", conflictMarker, theirsLabel)
+		}
+
+		o, a, b = nextO, nextA, nextB
+	}
+
+	result.Content = out.String()
+	return result
+}
+
+/**
+ * Indique si un contenu contient encore un bloc de conflit complet :
+ * une ligne de marqueur, puis une ligne de séparation, puis une seconde ligne de marqueur
+ */
+func hasConflictMarkers(content string) bool {
+	state := 0
+	for _, line := range diff.SplitLines(content) {
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case state != 1 && strings.HasPrefix(line, conflictMarker):
+			if state == 2 {
+				return true
+			}
+			state = 1
+		case state == 1 && line == conflictSeparator:
+			state = 2
+		}
+	}
+	return false
+}
+
+/**
+ * Associe chaque ligne de base à sa ligne correspondante dans l'autre version
+ * Retourne -1 pour les lignes de base supprimées ou modifiées
+ */
+func matchingLines(base, other []string) []int {
+	matches := make([]int, len(base))
+	for i := range matches {
+		matches[i] = -1
+	}
+	for _, edit := range diff.Lines(base, other) {
+		if edit.Op == diff.Equal {
+			matches[edit.OldIndex] = edit.NewIndex
+		}
+	}
+	return matches
+}
+
+func sameLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+/**
+ * Garantit que les marqueurs de conflit commencent sur une nouvelle ligne
+ */
+func ensureNewline(out *strings.Builder, lines []string) {
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteString("\n")
+	}
+}
@@ -0,0 +1,30 @@
+package merge
+
+import "testing"
+
+func TestMergeLines(t *testing.T) {
+	ours := conflictMarker + " main\n"
+	separator := conflictSeparator + "\n"
+	theirs := conflictMarker + " feature\n"
+
+	tests := []struct {
+		name              string
+		base, left, right string
+		want              string
+		conflicts         int
+	}{
+		{"empty", "", "", "", "", 0},
+		{"changed on one side", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nC\n", "a\nb\nC\n", 0},
+		{"distant changes on both sides", "a\nb\nc\nd\n", "A\nb\nc\nd\n", "a\nb\nc\nD\n", "A\nb\nc\nD\n", 0},
+		{"same change on both sides", "a\nb\n", "a\nX\n", "a\nX\n", "a\nX\n", 0},
+		{"different changes", "a\nb\nc\n", "a\nX\nc\n", "a\nY\nc\n", "a\n" + ours + "X\n" + separator + "Y\n" + theirs + "c\n", 1},
+		{"added from an empty base", "", "x\n", "y\n", ours + "x\n" + separator + "y\n" + theirs, 1},
+		{"no trailing newline", "a\n", "a\nx", "a\ny", "a\n" + ours + "x\n" + separator + "y\n" + theirs, 1},
+	}
+	for _, tt := range tests {
+		result := mergeLines(tt.base, tt.left, tt.right, "main", "feature")
+		if result.Content != tt.want || result.Conflicts != tt.conflicts {
+			t.Errorf("%s: got %q with %d conflict(s), want %q with %d", tt.name, result.Content, result.Conflicts, tt.want, tt.conflicts)
+		}
+	}
+}
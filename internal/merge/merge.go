@@ -1,18 +1,49 @@
 package merge
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"projet-go-git/internal/checkout"
+	"projet-go-git/internal/index"
 	"projet-go-git/internal/objects"
 	"projet-go-git/internal/repository"
+	"projet-go-git/internal/reset"
+	"sort"
 	"strings"
 )
 
-func Merge(branchName string) error {
-	branchPath := filepath.Join(".goit", "refs", "heads", branchName)
-	if _, err := os.Stat(branchPath); err != nil {
-		return fmt.Errorf("branch %s does not exist", branchName)
+/**
+ * Comportement vis-à-vis du fast-forward
+ * - FastForward : fast-forward si possible, sinon commit de merge
+ * - FastForwardOnly : refuse le merge s'il nécessite un commit de merge
+ * - NoFastForward : crée toujours un commit de merge
+ */
+const (
+	FastForward     = "ff"
+	FastForwardOnly = "ff-only"
+	NoFastForward   = "no-ff"
+)
+
+// Retournée par mergeTrees quand des fichiers restent en conflit (l'index contient alors leurs étapes)
+var ErrMergeConflicts = errors.New("merge conflicts detected")
+
+/**
+ * Fusionne une branche (ou toute révision : tag, hash, HEAD~n...) dans la branche actuelle
+ */
+func Merge(branchName, ffMode string) error {
+	if err := repository.RequireFullSnapshots(); err != nil {
+		return err
+	}
+
+	if isMergeInProgress() {
+		return fmt.Errorf("you have not concluded your merge (MERGE_HEAD exists); finish it with goit resolve or goit merge --abort")
+	}
+
+	branchHash, err := repository.ResolveRevision(branchName)
+	if err != nil {
+		return fmt.Errorf("%s - not something we can merge: %v", branchName, err)
 	}
 
 	currentBranch, err := repository.GetCurrentBranch()
@@ -29,107 +60,108 @@ func Merge(branchName string) error {
 		return fmt.Errorf("error getting current commit: %v", err)
 	}
 
-	branchHash, err := getBranchHash(branchName)
+	bases, err := repository.MergeBases(currentHash, branchHash)
 	if err != nil {
-		return fmt.Errorf("error getting branch commit: %v", err)
+		return fmt.Errorf("error computing merge base: %v", err)
 	}
-
-	commonAncestor := findCommonAncestor(currentHash, branchHash)
-	if commonAncestor == "" {
+	if len(bases) == 0 {
 		return fmt.Errorf("no common ancestor found")
 	}
 
-	if commonAncestor == branchHash {
+	if len(bases) == 1 && bases[0] == branchHash {
 		fmt.Printf("Already up to date with %s\n", branchName)
 		return nil
 	}
 
-	if commonAncestor == currentHash {
-		return fastForwardMerge(branchName, branchHash)
+	canFastForward := len(bases) == 1 && bases[0] == currentHash
+	if ffMode == FastForwardOnly && !canFastForward {
+		return fmt.Errorf("not possible to fast-forward, aborting")
 	}
 
-	return createMergeCommit(branchName, branchHash, currentHash)
-}
-
-func getBranchHash(branchName string) (string, error) {
-	branchPath := filepath.Join(".goit", "refs", "heads", branchName)
-	data, err := os.ReadFile(branchPath)
+	// Toutes les vérifications avant de toucher au dépôt : un merge refusé ne modifie rien
+	if canFastForward {
+		err = checkout.CheckUpdate(currentHash, branchHash, "merge")
+	} else {
+		err = checkout.CheckMergeable(currentHash, branchHash)
+	}
 	if err != nil {
-		return "", err
+		return err
 	}
-	return strings.TrimSpace(string(data)), nil
-}
 
-/**
- * Trouve l'ancêtre commun entre deux commits
- */
-func findCommonAncestor(commit1, commit2 string) string {
-	ancestors1 := getAncestors(commit1)
-	ancestors2 := getAncestors(commit2)
-
-	for _, ancestor := range ancestors1 {
-		for _, ancestor2 := range ancestors2 {
-			if ancestor == ancestor2 {
-				return ancestor
-			}
-		}
+	// Position avant le merge, pour pouvoir l'annuler avec goit reset --hard ORIG_HEAD
+	if err := repository.WriteOrigHead(currentHash); err != nil {
+		return err
 	}
-	return ""
+
+	if canFastForward && ffMode != NoFastForward {
+		return fastForwardMerge(branchName, branchHash, currentHash)
+	}
+	if canFastForward {
+		return noFastForwardMerge(branchName, branchHash, currentHash)
+	}
+	return createMergeCommit(branchName, branchHash, currentHash, bases)
 }
 
 /**
- * Récupère tous les ancêtres d'un commit
+ * Abandonne un merge en conflit (goit merge --abort)
+ * Le répertoire de travail et l'index reviennent à l'état d'avant le merge et MERGE_HEAD est supprimé
  */
-func getAncestors(commitHash string) []string {
-	var ancestors []string
-	hash := commitHash
-
-	for hash != "" {
-		ancestors = append(ancestors, hash)
-		hash = getParentHash(hash)
+func Abort() error {
+	if !isMergeInProgress() {
+		return fmt.Errorf("there is no merge to abort (MERGE_HEAD missing)")
 	}
-
-	return ancestors
+	return reset.Reset(reset.ModeMerge, "HEAD")
 }
 
 /**
- * Récupère le hash du parent d'un commit
+ * Effectue un fast-forward merge
+ * Le répertoire de travail et l'index passent au commit cible comme pour un checkout
+ * (refusé si des modifications locales seraient écrasées), puis la branche est déplacée
  */
-func getParentHash(commitHash string) string {
-	path := filepath.Join(".goit", "objects", commitHash)
-	data, err := os.ReadFile(path)
+func fastForwardMerge(branchName, branchHash, currentHash string) error {
+	currentBranch, err := repository.GetCurrentBranch()
 	if err != nil {
-		return ""
+		return err
 	}
 
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "parent ") {
-			return strings.TrimPrefix(line, "parent ")
-		}
+	if err := checkout.UpdateWorkingTree(currentHash, branchHash, "merge"); err != nil {
+		return err
 	}
 
-	return ""
+	if err := repository.UpdateHEAD(branchHash, "merge "+branchName+": Fast-forward"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updating %s..%s\n", currentHash[:8], branchHash[:8])
+	fmt.Printf("Fast-forward merge: %s -> %s\n", branchName, currentBranch)
+	return nil
 }
 
 /**
- * Effectue un fast-forward merge
- * Met à jour la branche actuelle vers la branche cible
+ * Crée un commit de merge alors qu'un fast-forward était possible (--no-ff)
+ * L'arbre du commit est celui de la branche fusionnée
  */
-func fastForwardMerge(branchName, branchHash string) error {
-	// Mettre à jour la référence de la branche actuelle
-	currentBranch, err := repository.GetCurrentBranch()
-	if err != nil {
+func noFastForwardMerge(branchName, branchHash, currentHash string) error {
+	if err := checkout.UpdateWorkingTree(currentHash, branchHash, "merge"); err != nil {
 		return err
 	}
 
-	branchRefPath := filepath.Join(".goit", "refs", "heads", currentBranch)
-	if err := os.WriteFile(branchRefPath, []byte(branchHash), 0644); err != nil {
-		return fmt.Errorf("failed to update branch reference: %v", err)
+	branchTree, err := getCommitTree(branchHash)
+	if err != nil {
+		return fmt.Errorf("error getting branch tree: %v", err)
 	}
 
-	fmt.Printf("Fast-forward merge: %s -> %s\n", branchName, currentBranch)
+	message := getMergeMessage(branchName)
+	commitHash, err := objects.CreateCommit(branchTree, message, []string{currentHash, branchHash})
+	if err != nil {
+		return fmt.Errorf("error creating merge commit: %v", err)
+	}
+
+	if err := repository.UpdateHEAD(commitHash, "merge "+branchName); err != nil {
+		return err
+	}
+
+	fmt.Printf("Merge commit created: %s\n", commitHash[:8])
 	return nil
 }
 
@@ -137,7 +169,12 @@ func fastForwardMerge(branchName, branchHash string) error {
  * Crée un commit de merge
  * Fusionne les arbres et crée un nouveau commit avec deux parents
  */
-func createMergeCommit(branchName, branchHash, currentHash string) error {
+func createMergeCommit(branchName, branchHash, currentHash string, bases []string) error {
+	baseTree, err := getBaseTree(bases)
+	if err != nil {
+		return fmt.Errorf("error getting base tree: %v", err)
+	}
+
 	currentTree, err := getCommitTree(currentHash)
 	if err != nil {
 		return fmt.Errorf("error getting current tree: %v", err)
@@ -148,204 +185,551 @@ func createMergeCommit(branchName, branchHash, currentHash string) error {
 		return fmt.Errorf("error getting branch tree: %v", err)
 	}
 
-	mergedTree, err := mergeTrees(currentTree, branchTree, branchName)
+	mergedTree, err := mergeTrees(baseTree, currentTree, branchTree, branchName, false)
 	if err != nil {
-		if err.Error() == "merge conflicts detected" {
-			fmt.Println("Automatic merge failed\n Fix conflicts and then commit the result")
+		if errors.Is(err, ErrMergeConflicts) {
+			// Sans MERGE_HEAD, ni goit resolve ni goit merge --abort ne sauraient qu'un merge est en cours
 			repoRoot := findRepoRoot()
-			os.WriteFile(filepath.Join(repoRoot, ".goit", "MERGE_HEAD"), []byte(branchHash), 0644)
+			if err := os.WriteFile(filepath.Join(repoRoot, ".goit", "MERGE_HEAD"), []byte(branchHash), 0644); err != nil {
+				return fmt.Errorf("failed to write MERGE_HEAD: %v", err)
+			}
+			fmt.Println("Automatic merge failed\n Fix conflicts and then commit the result")
 			return nil
 		}
 		return fmt.Errorf("error merging trees: %v", err)
 	}
 
 	message := getMergeMessage(branchName)
-	commitHash := objects.CreateCommit(mergedTree, message, currentHash)
-
-	if err := addSecondParent(commitHash, branchHash); err != nil {
-		return fmt.Errorf("error adding second parent: %v", err)
+	commitHash, err := objects.CreateCommit(mergedTree, message, []string{currentHash, branchHash})
+	if err != nil {
+		return fmt.Errorf("error creating merge commit: %v", err)
 	}
 
-	currentBranch, err := repository.GetCurrentBranch()
-	if err != nil {
+	if err := repository.UpdateHEAD(commitHash, "merge "+branchName); err != nil {
 		return err
 	}
 
-	branchRefPath := filepath.Join(".goit", "refs", "heads", currentBranch)
-	if err := os.WriteFile(branchRefPath, []byte(commitHash), 0644); err != nil {
-		return fmt.Errorf("failed to update branch reference: %v", err)
+	if err := syncIndexWithCommit(commitHash); err != nil {
+		return fmt.Errorf("error syncing index: %v", err)
 	}
 
-	syncIndexWithCommit(commitHash)
-
 	fmt.Printf("Merge commit created: %s\n", commitHash[:8])
 	return nil
 }
 
 /**
- * Récupère le hash de l'arbre d'un commit
+ * Calcule l'arbre servant de base à la fusion
+ * Avec plusieurs bases (merges croisés), les bases sont fusionnées entre elles
+ * pour produire un ancêtre virtuel, à la manière de la stratégie "recursive" de Git
  */
-func getCommitTree(commitHash string) (string, error) {
-	path := filepath.Join(".goit", "objects", commitHash)
-	data, err := os.ReadFile(path)
+func getBaseTree(bases []string) (string, error) {
+	tree, err := getCommitTree(bases[0])
 	if err != nil {
 		return "", err
 	}
 
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, " tree ") {
-			return strings.TrimPrefix(line, " tree "), nil
+	for _, other := range bases[1:] {
+		otherTree, err := getCommitTree(other)
+		if err != nil {
+			return "", err
+		}
+
+		subBases, err := repository.MergeBases(bases[0], other)
+		if err != nil {
+			return "", err
+		}
+
+		var subBaseTree string
+		if len(subBases) > 0 {
+			if subBaseTree, err = getBaseTree(subBases); err != nil {
+				return "", err
+			}
+		}
+
+		if tree, err = mergeTrees(subBaseTree, tree, otherTree, other[:8], true); err != nil {
+			return "", err
 		}
 	}
 
-	return "", fmt.Errorf("no tree found in commit")
+	return tree, nil
 }
 
 /**
- * Fusionne deux arbres avec gestion des conflits
- * Compare les fichiers et détecte les conflits
+ * Récupère le hash de l'arbre d'un commit
  */
-func mergeTrees(tree1, tree2, branchName string) (string, error) {
-	files1 := getTreeFiles(tree1)
-	files2 := getTreeFiles(tree2)
+func getCommitTree(commitHash string) (string, error) {
+	return objects.GetCommitTree(commitHash)
+}
 
-	mergedFiles := make(map[string]string)
-	hasConflicts := false
+/**
+ * État d'une fusion d'arbres
+ * files contient le résultat (chemin -> entrée), conflicts les versions des fichiers en conflit
+ */
+type treeMerge struct {
+	branchName   string
+	virtual      bool
+	files        map[string]objects.FileEntry
+	conflicts    map[string]index.Conflict
+	hasConflicts bool
+	// Modifications du répertoire de travail, appliquées une fois toute la fusion calculée
+	worktreeOps []func() error
+}
 
-	allFiles := make(map[string]bool)
-	for filename := range files1 {
-		allFiles[filename] = true
-	}
-	for filename := range files2 {
-		allFiles[filename] = true
+/**
+ * Programme une modification du répertoire de travail (ignorée en mode virtuel)
+ * Rien n'est écrit tant qu'un objet peut encore manquer ou être illisible
+ */
+func (m *treeMerge) later(op func() error) {
+	if !m.virtual {
+		m.worktreeOps = append(m.worktreeOps, op)
 	}
+}
 
-	for filename := range allFiles {
-		hash1, exists1 := files1[filename]
-		hash2, exists2 := files2[filename]
+/**
+ * Fusionne deux arbres avec gestion des conflits
+ * Compare les fichiers à l'aide de l'arbre de l'ancêtre commun et détecte les conflits
+ * En mode virtuel (ancêtre virtuel), le répertoire de travail n'est pas modifié
+ * et les conflits sont conservés avec leurs marqueurs dans l'arbre produit
+ */
+func mergeTrees(baseTree, tree1, tree2, branchName string, virtual bool) (string, error) {
+	m := &treeMerge{
+		branchName: branchName,
+		virtual:    virtual,
+		files:      make(map[string]objects.FileEntry),
+		conflicts:  make(map[string]index.Conflict),
+	}
 
-		if !exists1 {
-			mergedFiles[filename] = hash2
-		} else if !exists2 {
-			mergedFiles[filename] = hash1
-		} else if hash1 == hash2 {
-			mergedFiles[filename] = hash1
-		} else {
-			mergedHash, conflict := mergeFile(filename, hash1, hash2, branchName)
-			if conflict {
-				hasConflicts = true
-				fmt.Printf("\033[33mCONFLICT (content): Merge conflict in \033[1m%s\033[0m\033[33m\033[0m\n", filename)
-			}
-			mergedFiles[filename] = mergedHash
-		}
+	if err := m.mergeLevel(baseTree, tree1, tree2, ""); err != nil {
+		return "", err
 	}
 
-	treeContent := "tree\n"
-	for filename, hash := range mergedFiles {
-		treeContent += fmt.Sprintf("%s %s\n", hash, filename)
+	treeHash, err := objects.WriteTree(m.files)
+	if err != nil {
+		return "", fmt.Errorf("error writing merged tree: %v", err)
 	}
 
-	treeHash := objects.HashContent(treeContent)
-	treePath := filepath.Join(".goit", "objects", treeHash)
-	os.WriteFile(treePath, []byte(treeContent), 0644)
+	for _, op := range m.worktreeOps {
+		if err := op(); err != nil {
+			return "", err
+		}
+	}
 
-	if hasConflicts {
-		return "", fmt.Errorf("merge conflicts detected")
+	if m.hasConflicts && !virtual {
+		// L'index reçoit le résultat de la fusion, les fichiers en conflit y sont enregistrés
+		// avec leurs trois versions (ancêtre, actuelle, fusionnée) à la place de l'étape 0
+		if err := index.WriteEntriesWithConflicts(m.files, m.conflicts); err != nil {
+			return "", fmt.Errorf("error writing index: %v", err)
+		}
+		return "", ErrMergeConflicts
 	}
 
 	return treeHash, nil
 }
 
 /**
- * Récupère les fichiers d'un arbre
+ * Fusionne un niveau de l'arborescence (un répertoire)
+ * Les sous-répertoires identiques des deux côtés, ou inchangés sur la branche fusionnée,
+ * sont repris en bloc sans parcourir leurs fichiers
  */
-func getTreeFiles(treeHash string) map[string]string {
-	files := make(map[string]string)
+func (m *treeMerge) mergeLevel(baseTree, oursTree, theirsTree, prefix string) error {
+	if oursTree == theirsTree || baseTree == theirsTree {
+		return m.takeTree(oursTree, prefix, false)
+	}
 
-	treePath := filepath.Join(".goit", "objects", treeHash)
-	treeData, err := os.ReadFile(treePath)
+	baseEntries, err := readTreeEntries(baseTree)
+	if err != nil {
+		return err
+	}
+	oursEntries, err := readTreeEntries(oursTree)
 	if err != nil {
-		return files
+		return err
+	}
+	theirsEntries, err := readTreeEntries(theirsTree)
+	if err != nil {
+		return err
 	}
 
-	lines := strings.Split(string(treeData), "\n")
-	for _, line := range lines {
-		if line != "" && !strings.HasPrefix(line, "tree") {
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				hash, filename := parts[0], parts[1]
-				files[filename] = hash
+	// Parcours dans un ordre stable pour que les messages de conflit soient reproductibles
+	var names []string
+	for name := range oursEntries {
+		names = append(names, name)
+	}
+	for name := range theirsEntries {
+		if _, exists := oursEntries[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		base, existsBase := baseEntries[name]
+		ours, existsOurs := oursEntries[name]
+		theirs, existsTheirs := theirsEntries[name]
+
+		var err error
+		switch {
+		case !existsTheirs && !existsBase:
+			// Ajouté uniquement sur la branche actuelle
+			err = m.take(ours, path, false)
+		case !existsOurs && !existsBase:
+			// Ajouté uniquement sur la branche fusionnée
+			err = m.take(theirs, path, true)
+		case !existsTheirs:
+			err = m.mergeDeleted(path, base, ours, false)
+		case !existsOurs:
+			err = m.mergeDeleted(path, base, theirs, true)
+		case ours.Type == "tree" && theirs.Type == "tree":
+			baseHash := ""
+			if existsBase && base.Type == "tree" {
+				baseHash = base.Hash
+			}
+			err = m.mergeLevel(baseHash, ours.Hash, theirs.Hash, path+"/")
+		case ours.Type != theirs.Type:
+			err = m.mergeFileDir(path, base, existsBase, ours, theirs, func(name string) bool {
+				_, inOurs := oursEntries[name]
+				_, inTheirs := theirsEntries[name]
+				return inOurs || inTheirs
+			})
+		default:
+			var baseEntry *objects.TreeEntry
+			if existsBase && base.Type == "blob" {
+				baseEntry = &base
 			}
+			err = m.mergeBlob(path, baseEntry, ours, theirs)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
-	return files
+	return nil
 }
 
 /**
- * Fusionne un fichier avec gestion des conflits
- * Retourne le hash du fichier fusionné et s'il y a un conflit
+ * Fusionne une entrée supprimée d'un côté et conservée (kept) de l'autre
+ * Inchangée depuis l'ancêtre commun, la suppression est reprise ; modifiée, c'est un conflit
+ * modify/delete et la version modifiée reste dans le répertoire de travail
  */
-func mergeFile(filename, hash1, hash2, branchName string) (string, bool) {
-	content1 := getFileContent(hash1)
-	content2 := getFileContent(hash2)
+func (m *treeMerge) mergeDeleted(path string, base, kept objects.TreeEntry, keptIsTheirs bool) error {
+	if kept == base {
+		if keptIsTheirs {
+			// Supprimé sur la branche actuelle : il est déjà absent
+			return nil
+		}
+		return m.remove(kept, path)
+	}
 
-	if content1 == content2 {
-		return hash1, false
+	if kept.Type != base.Type {
+		// Fichier remplacé par un répertoire (ou l'inverse) : l'ancienne entrée a disparu des deux côtés
+		return m.take(kept, path, keptIsTheirs)
 	}
+	if kept.Type == "tree" {
+		if keptIsTheirs {
+			return m.mergeLevel(base.Hash, "", kept.Hash, path+"/")
+		}
+		return m.mergeLevel(base.Hash, kept.Hash, "", path+"/")
+	}
+
+	m.hasConflicts = true
+	file := objects.FileEntry{Mode: kept.Mode, Hash: kept.Hash}
+	conflict := index.Conflict{Base: blobEntry(&base)}
+	if keptIsTheirs {
+		conflict.Theirs = &file
+	} else {
+		conflict.Ours = &file
+	}
+	m.conflicts[path] = conflict
+	if !m.virtual {
+		deletedIn, modifiedIn := m.branchName, "HEAD"
+		if keptIsTheirs {
+			deletedIn, modifiedIn = "HEAD", m.branchName
+		}
+		fmt.Printf("\033[33mCONFLICT (modify/delete): \033[1m%s\033[0m\033[33m deleted in %s and modified in %s. Version %s left in tree.\033[0m\n",
+			path, deletedIn, modifiedIn, modifiedIn)
+	}
+	return m.take(kept, path, keptIsTheirs)
+}
 
-	currentBranch, _ := repository.GetCurrentBranch()
+/**
+ * Fusionne un chemin qui est un fichier d'un côté et un répertoire de l'autre
+ * Le répertoire est conservé à sa place et le fichier est mis en conflit sous le nom
+ * <chemin>~<branche> ; taken indique si un nom est déjà pris dans le répertoire fusionné
+ */
+func (m *treeMerge) mergeFileDir(path string, base objects.TreeEntry, existsBase bool, ours, theirs objects.TreeEntry, taken func(name string) bool) error {
+	file, dir, fileIsTheirs := ours, theirs, false
+	side := "HEAD"
+	if ours.Type == "tree" {
+		file, dir, fileIsTheirs = theirs, ours, true
+		side = m.branchName
+	}
+
+	prefix, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix, name = path[:i+1], path[i+1:]
+	}
+	renamed := name + "~" + strings.ReplaceAll(side, "/", "_")
+	for i := 1; taken(renamed); i++ {
+		renamed = fmt.Sprintf("%s~%s_%d", name, strings.ReplaceAll(side, "/", "_"), i)
+	}
+	renamed = prefix + renamed
 
-	mergedContent := fmt.Sprintf("************** %s\n%s\n=========\n%s\n************** %s\n",
-		currentBranch, content1, content2, branchName)
+	m.hasConflicts = true
+	m.conflicts[renamed] = fileDirConflict(base, existsBase, ours, theirs)
+	fileEntry := objects.FileEntry{Mode: file.Mode, Hash: file.Hash}
+	m.files[renamed] = fileEntry
 
-	mergedHash := objects.HashContent(mergedContent)
-	objectPath := filepath.Join(".goit", "objects", mergedHash)
-	os.WriteFile(objectPath, []byte(mergedContent), 0644)
+	if !m.virtual {
+		dirSide := m.branchName
+		if fileIsTheirs {
+			dirSide = "HEAD"
+		}
+		fmt.Printf("\033[33mCONFLICT (file/directory): There is a directory with name \033[1m%s\033[0m\033[33m in %s. Adding %s as %s\033[0m\n",
+			path, dirSide, path, renamed)
 
-	os.WriteFile(filename, []byte(mergedContent), 0644)
+		m.later(func() error {
+			if err := objects.WriteWorkingFile(renamed, fileEntry); err != nil {
+				return fmt.Errorf("failed to write %s: %v", renamed, err)
+			}
+			// Le fichier de la branche actuelle doit laisser sa place au répertoire
+			if !fileIsTheirs {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to remove %s: %v", path, err)
+				}
+			}
+			return nil
+		})
+	}
 
-	return mergedHash, true
+	return m.takeTree(dir.Hash, path+"/", !fileIsTheirs)
 }
 
 /**
- * Récupère le contenu d'un fichier depuis son hash
+ * Supprime du répertoire de travail un fichier (ou répertoire) supprimé par la branche fusionnée
  */
-func getFileContent(hash string) string {
-	objectPath := filepath.Join(".goit", "objects", hash)
-	content, err := os.ReadFile(objectPath)
-	if err != nil {
-		return ""
+func (m *treeMerge) remove(entry objects.TreeEntry, path string) error {
+	if m.virtual {
+		return nil
 	}
-	return string(content)
+
+	paths := []string{path}
+	if entry.Type == "tree" {
+		files, err := objects.ReadTreeFiles(entry.Hash)
+		if err != nil {
+			return err
+		}
+		paths = paths[:0]
+		for filename := range files {
+			paths = append(paths, path+"/"+filename)
+		}
+	}
+
+	m.later(func() error {
+		for _, file := range paths {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %v", file, err)
+			}
+			// Supprimer les répertoires parents devenus vides
+			for dir := filepath.Dir(file); dir != "."; dir = filepath.Dir(dir) {
+				if os.Remove(dir) != nil {
+					break
+				}
+			}
+		}
+		return nil
+	})
+	return nil
 }
 
 /**
- * Ajoute un second parent à un commit
+ * Reprend une entrée telle quelle dans le résultat
+ * fromTheirs indique que l'entrée vient de la branche fusionnée et doit être écrite dans le répertoire de travail
  */
-func addSecondParent(commitHash, parentHash string) error {
-	path := filepath.Join(".goit", "objects", commitHash)
-	data, err := os.ReadFile(path)
+func (m *treeMerge) take(entry objects.TreeEntry, path string, fromTheirs bool) error {
+	if entry.Type == "tree" {
+		return m.takeTree(entry.Hash, path+"/", fromTheirs)
+	}
+
+	file := objects.FileEntry{Mode: entry.Mode, Hash: entry.Hash}
+	m.files[path] = file
+	if fromTheirs {
+		m.later(func() error { return objects.WriteWorkingFile(path, file) })
+	}
+	return nil
+}
+
+func (m *treeMerge) takeTree(treeHash, prefix string, fromTheirs bool) error {
+	files, err := objects.ReadTreeFiles(treeHash)
 	if err != nil {
 		return err
 	}
 
-	lines := strings.Split(string(data), "\n")
-	var newLines []string
+	for filename, file := range files {
+		path := prefix + filename
+		m.files[path] = file
+		if fromTheirs {
+			m.later(func() error { return objects.WriteWorkingFile(path, file) })
+		}
+	}
+	return nil
+}
+
+/**
+ * Fusionne un fichier présent des deux côtés (contenu et mode)
+ */
+func (m *treeMerge) mergeBlob(path string, base *objects.TreeEntry, ours, theirs objects.TreeEntry) error {
+	oursFile := objects.FileEntry{Mode: ours.Mode, Hash: ours.Hash}
+
+	// Le mode modifié d'un seul côté est repris
+	mode := ours.Mode
+	if ours.Mode != theirs.Mode && base != nil && ours.Mode == base.Mode {
+		mode = theirs.Mode
+	}
+
+	hash := ours.Hash
+	conflict := false
+	if ours.Hash != theirs.Hash {
+		baseHash := ""
+		if base != nil {
+			baseHash = base.Hash
+		}
 
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			// Insérer le parent avant la ligne vide
-			newLines = append(newLines, fmt.Sprintf("parent %s", parentHash))
-			newLines = append(newLines, line)
+		if ours.Mode == objects.ModeSymlink || theirs.Mode == objects.ModeSymlink {
+			// Les cibles de liens symboliques ne se fusionnent pas ligne par ligne
+			switch baseHash {
+			case ours.Hash:
+				hash = theirs.Hash
+				mode = theirs.Mode
+			case theirs.Hash:
+			default:
+				conflict = true
+			}
 		} else {
-			newLines = append(newLines, line)
+			var err error
+			hash, conflict, err = mergeFile(path, baseHash, ours.Hash, theirs.Hash, m.branchName)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
-	newData := strings.Join(newLines, "\n")
-	return os.WriteFile(path, []byte(newData), 0644)
+	merged := objects.FileEntry{Mode: mode, Hash: hash}
+	m.files[path] = merged
+
+	if conflict {
+		m.hasConflicts = true
+		theirsFile := objects.FileEntry{Mode: theirs.Mode, Hash: theirs.Hash}
+		m.conflicts[path] = index.Conflict{Base: blobEntry(base), Ours: &oursFile, Theirs: &theirsFile}
+		if !m.virtual {
+			fmt.Printf("\033[33mCONFLICT (content): Merge conflict in \033[1m%s\033[0m\033[33m\033[0m\n", path)
+		}
+	}
+
+	if merged != oursFile {
+		m.later(func() error {
+			if err := objects.WriteWorkingFile(path, merged); err != nil {
+				return fmt.Errorf("failed to write %s: %v", path, err)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+/**
+ * Versions d'un conflit fichier/répertoire : seul le côté où le chemin est un fichier a une version
+ */
+func fileDirConflict(base objects.TreeEntry, existsBase bool, ours, theirs objects.TreeEntry) index.Conflict {
+	var conflict index.Conflict
+	if existsBase {
+		conflict.Base = blobEntry(&base)
+	}
+	if ours.Type == "blob" {
+		conflict.Ours = &objects.FileEntry{Mode: ours.Mode, Hash: ours.Hash}
+	} else {
+		conflict.Theirs = &objects.FileEntry{Mode: theirs.Mode, Hash: theirs.Hash}
+	}
+	return conflict
+}
+
+/**
+ * Entrée de fichier correspondant à une entrée d'arbre, nil si elle est absente ou n'est pas un fichier
+ */
+func blobEntry(entry *objects.TreeEntry) *objects.FileEntry {
+	if entry == nil || entry.Type != "blob" {
+		return nil
+	}
+	return &objects.FileEntry{Mode: entry.Mode, Hash: entry.Hash}
+}
+
+/**
+ * Lit les entrées d'un arbre indexées par nom
+ */
+func readTreeEntries(treeHash string) (map[string]objects.TreeEntry, error) {
+	entries, err := objects.ReadTree(treeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]objects.TreeEntry)
+	for _, entry := range entries {
+		byName[entry.Name] = entry
+	}
+	return byName, nil
+}
+
+/**
+ * Fusionne un fichier à trois voies à partir de sa version dans l'ancêtre commun
+ * Les modifications qui ne se chevauchent pas sont fusionnées automatiquement
+ * Retourne le hash du fichier fusionné et s'il y a un conflit
+ */
+func mergeFile(filename, baseHash, hash1, hash2, branchName string) (string, bool, error) {
+	content1, err := getFileContent(hash1)
+	if err != nil {
+		return "", false, fmt.Errorf("cannot read %s: %v", filename, err)
+	}
+	content2, err := getFileContent(hash2)
+	if err != nil {
+		return "", false, fmt.Errorf("cannot read %s: %v", filename, err)
+	}
+
+	if content1 == content2 {
+		return hash1, false, nil
+	}
+
+	baseContent, err := getFileContent(baseHash)
+	if err != nil {
+		return "", false, fmt.Errorf("cannot read common ancestor of %s: %v", filename, err)
+	}
+
+	currentBranch, _ := repository.GetCurrentBranch()
+
+	result := mergeLines(baseContent, content1, content2, currentBranch, branchName)
+	if result.Content == content1 {
+		return hash1, false, nil
+	}
+
+	mergedHash, err := objects.WriteBlob([]byte(result.Content))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to write merged %s: %v", filename, err)
+	}
+
+	return mergedHash, result.Conflicts > 0, nil
+}
+
+/**
+ * Récupère le contenu d'un fichier depuis son hash
+ * Un hash vide (version absente) correspond à un contenu vide ; un blob manquant ou illisible est une erreur
+ */
+func getFileContent(hash string) (string, error) {
+	if hash == "" {
+		return "", nil
+	}
+	content, err := objects.ReadBlob(hash)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
 }
 
 /**
@@ -383,21 +767,54 @@ func getMergeMessage(branchName string) string {
 /**
  * Finalise un merge après résolution des conflits
  * Crée le commit de merge final
+ * Refuse tant qu'un chemin en conflit n'a pas été ajouté, ou qu'un fichier fusionné
+ * contient encore des marqueurs de conflit (sauf avec force)
  */
-func Resolve() error {
+func Resolve(force bool) error {
 	if !isMergeInProgress() {
 		return fmt.Errorf("no merge conflicts to resolve")
 	}
 
+	if err := repository.RequireFullSnapshots(); err != nil {
+		return err
+	}
+
 	repoRoot := findRepoRoot()
 
-	indexPath := filepath.Join(repoRoot, ".goit", "index")
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		os.WriteFile(indexPath, []byte(""), 0644)
+	if err := index.EnsureIndex(); err != nil {
+		return fmt.Errorf("error preparing index: %v", err)
+	}
+
+	// Les chemins en conflit gardent leurs étapes dans l'index tant qu'ils n'ont pas été ajoutés
+	unmerged, err := index.UnmergedPaths()
+	if err != nil {
+		return fmt.Errorf("error reading index: %v", err)
+	}
+	if len(unmerged) > 0 {
+		return fmt.Errorf("you need to resolve your current index first:\n\t%s\nfix the conflicts and mark them as resolved with goit add <file>",
+			strings.Join(unmerged, "\n\t"))
 	}
 
-	// Utiliser seulement les fichiers déjà dans l'index
-	treeHash := objects.CreateTree()
+	// L'index contient l'instantané complet du résultat de la fusion
+	files, err := index.ReadFiles()
+	if err != nil {
+		return fmt.Errorf("error reading index: %v", err)
+	}
+
+	if !force {
+		marked, err := filesWithConflictMarkers(files)
+		if err != nil {
+			return err
+		}
+		if len(marked) > 0 {
+			return fmt.Errorf("the following files still contain conflict markers:\n\t%s\nfix them and add them again, or use goit resolve --force to keep the markers",
+				strings.Join(marked, "\n\t"))
+		}
+	}
+	treeHash, err := objects.WriteTree(files)
+	if err != nil {
+		return fmt.Errorf("error writing tree: %v", err)
+	}
 
 	currentHash, err := repository.GetCurrentCommitHash()
 	if err != nil {
@@ -430,21 +847,14 @@ func Resolve() error {
 	}
 
 	message := getMergeMessage(branchName)
-	commitHash := objects.CreateCommit(treeHash, message, currentHash)
-
-	if err := addSecondParent(commitHash, branchHash); err != nil {
-		return fmt.Errorf("error adding second parent: %v", err)
-	}
-
-	// Mettre à jour la référence de la branche actuelle
-	currentBranch, err := repository.GetCurrentBranch()
+	commitHash, err := objects.CreateCommit(treeHash, message, []string{currentHash, branchHash})
 	if err != nil {
-		return err
+		return fmt.Errorf("error creating merge commit: %v", err)
 	}
 
-	branchRefPath := filepath.Join(repoRoot, ".goit", "refs", "heads", currentBranch)
-	if err := os.WriteFile(branchRefPath, []byte(commitHash), 0644); err != nil {
-		return fmt.Errorf("failed to update branch reference: %v", err)
+	// Mettre à jour la branche actuelle (ou HEAD s'il est détaché)
+	if err := repository.UpdateHEAD(commitHash, "commit (merge): "+message); err != nil {
+		return err
 	}
 
 	os.Remove(filepath.Join(repoRoot, ".goit", "MERGE_HEAD"))
@@ -457,26 +867,47 @@ func Resolve() error {
 	return nil
 }
 
-func syncIndexWithCommit(commitHash string) error {
-	repoRoot := findRepoRoot()
-	indexPath := filepath.Join(repoRoot, ".goit", "index")
-
-	treeHash, err := getCommitTree(commitHash)
-	if err != nil {
-		return fmt.Errorf("error getting commit tree: %v", err)
+/**
+ * Liste les fichiers stagés produits par la fusion (différents des deux parents)
+ * dont le contenu contient encore des marqueurs de conflit
+ */
+func filesWithConflictMarkers(files map[string]objects.FileEntry) ([]string, error) {
+	var oursFiles, theirsFiles map[string]objects.FileEntry
+	if currentHash, err := repository.GetCurrentCommitHash(); err == nil && currentHash != "" {
+		oursFiles, _ = objects.ReadCommitFiles(currentHash)
+	}
+	if mergeHash, err := repository.ReadRef("MERGE_HEAD"); err == nil {
+		theirsFiles, _ = objects.ReadCommitFiles(mergeHash)
 	}
 
-	files := getTreeFiles(treeHash)
-
-	var indexLines []string
-	for filename, hash := range files {
-		indexLines = append(indexLines, fmt.Sprintf("%s %s", hash, filename))
+	var marked []string
+	for path, entry := range files {
+		if ours, exists := oursFiles[path]; exists && ours == entry {
+			continue
+		}
+		if theirs, exists := theirsFiles[path]; exists && theirs == entry {
+			continue
+		}
+		if entry.Mode == objects.ModeSymlink {
+			continue
+		}
+		content, err := getFileContent(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %v", path, err)
+		}
+		if hasConflictMarkers(content) {
+			marked = append(marked, path)
+		}
 	}
+	sort.Strings(marked)
+	return marked, nil
+}
 
-	content := strings.Join(indexLines, "\n")
-	if len(indexLines) > 0 {
-		content += "\n"
+func syncIndexWithCommit(commitHash string) error {
+	files, err := objects.ReadCommitFiles(commitHash)
+	if err != nil {
+		return fmt.Errorf("error getting commit tree: %v", err)
 	}
 
-	return os.WriteFile(indexPath, []byte(content), 0644)
+	return index.WriteEntries(files)
 }
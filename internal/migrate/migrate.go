@@ -0,0 +1,397 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"projet-go-git/internal/config"
+	"projet-go-git/internal/index"
+	"projet-go-git/internal/objects"
+	"projet-go-git/internal/repository"
+	"strings"
+)
+
+/**
+ * Réécrit l'historique depuis un commit en remontant vers ses parents
+ * transform peut modifier le commit avant qu'il soit réécrit
+ * rewritten associe chaque ancien hash à son nouveau hash
+ */
+type historyRewriter struct {
+	transform func(hash string, commit *objects.Commit) error
+	rewritten map[string]string
+}
+
+func newHistoryRewriter(transform func(hash string, commit *objects.Commit) error) *historyRewriter {
+	return &historyRewriter{
+		transform: transform,
+		rewritten: make(map[string]string),
+	}
+}
+
+/**
+ * Réécrit un commit et tous ses ancêtres
+ * Un commit n'est réécrit que si son contenu change (parent réécrit ou transform)
+ * ou si son hash ne correspond pas à son contenu ; sinon son hash est conservé
+ */
+func (r *historyRewriter) rewrite(hash string) (string, error) {
+	if newHash, ok := r.rewritten[hash]; ok {
+		return newHash, nil
+	}
+
+	commit, err := objects.ReadCommit(hash)
+	if err != nil {
+		return "", err
+	}
+	original := string(objects.Encode(commit))
+
+	for i, parent := range commit.Parents {
+		newParent, err := r.rewrite(parent)
+		if err != nil {
+			return "", err
+		}
+		commit.Parents[i] = newParent
+	}
+
+	if r.transform != nil {
+		if err := r.transform(hash, commit); err != nil {
+			return "", err
+		}
+	}
+
+	if string(objects.Encode(commit)) == original {
+		valid, err := objects.HashMatchesContent(hash)
+		if err != nil {
+			return "", err
+		}
+		if valid {
+			r.rewritten[hash] = hash
+			return hash, nil
+		}
+	}
+
+	newHash, err := objects.WriteCommit(commit)
+	if err != nil {
+		return "", err
+	}
+	r.rewritten[hash] = newHash
+	return newHash, nil
+}
+
+/**
+ * Nombre de commits dont le hash a changé
+ */
+func (r *historyRewriter) changedCount() int {
+	count := 0
+	for oldHash, newHash := range r.rewritten {
+		if oldHash != newHash {
+			count++
+		}
+	}
+	return count
+}
+
+/**
+ * Liste les fichiers de référence pointant vers un commit
+ * (branches, tags, HEAD détaché, MERGE_HEAD)
+ */
+func listRefFiles() []string {
+	var refFiles []string
+
+	for _, refDir := range []string{"heads", "tags"} {
+		dir := filepath.Join(".goit", "refs", refDir)
+		if entries, err := os.ReadDir(dir); err == nil {
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					refFiles = append(refFiles, filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+	}
+
+	headPath := filepath.Join(".goit", "HEAD")
+	if head, err := os.ReadFile(headPath); err == nil && !strings.HasPrefix(string(head), "ref: ") {
+		refFiles = append(refFiles, headPath)
+	}
+
+	mergeHeadPath := filepath.Join(".goit", "MERGE_HEAD")
+	if _, err := os.Stat(mergeHeadPath); err == nil {
+		refFiles = append(refFiles, mergeHeadPath)
+	}
+
+	return refFiles
+}
+
+/**
+ * Réécrit l'historique accessible depuis toutes les références puis met à jour ces références
+ * Les anciens objets sont conservés, seuls de nouveaux objets sont ajoutés
+ */
+func rewriteAllRefs(r *historyRewriter) (int, error) {
+	updatedRefs := 0
+	for _, refFile := range listRefFiles() {
+		data, err := os.ReadFile(refFile)
+		if err != nil {
+			return updatedRefs, err
+		}
+		hash := strings.TrimSpace(string(data))
+		if hash == "" {
+			continue
+		}
+
+		newHash, err := r.rewrite(hash)
+		if err != nil {
+			return updatedRefs, err
+		}
+		if newHash == hash {
+			continue
+		}
+
+		if err := os.WriteFile(refFile, []byte(newHash), 0644); err != nil {
+			return updatedRefs, fmt.Errorf("failed to update %s: %v", refFile, err)
+		}
+		updatedRefs++
+	}
+	return updatedRefs, nil
+}
+
+/**
+ * Recalcule le hash des commits de merge corrompus
+ * Les anciens commits de merge étaient modifiés après le calcul de leur hash
+ * (ajout du second parent), leur nom de fichier ne correspondait donc plus à leur contenu.
+ * Les descendants de ces commits sont réécrits pour pointer vers les nouveaux hashes.
+ */
+func RehashMergeCommits() error {
+	rewriter := newHistoryRewriter(nil)
+	updatedRefs, err := rewriteAllRefs(rewriter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Rewrote %d commit(s), updated %d reference(s)\n", rewriter.changedCount(), updatedRefs)
+	return nil
+}
+
+/**
+ * Récupère les fichiers de l'arbre d'un commit (chemin -> entrée)
+ */
+func readCommitFiles(commitHash string) (map[string]objects.FileEntry, error) {
+	return objects.ReadCommitFiles(commitHash)
+}
+
+/**
+ * Combine les instantanés des parents d'un commit
+ * Pour un commit de merge, un fichier n'est repris d'un autre parent
+ * que si le premier parent ne l'a pas modifié depuis leur base commune
+ */
+func parentsSnapshot(parents []string) (map[string]objects.FileEntry, error) {
+	files, err := readCommitFiles(parents[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, other := range parents[1:] {
+		baseFiles := make(map[string]objects.FileEntry)
+		bases, err := repository.MergeBases(parents[0], other)
+		if err != nil {
+			return nil, err
+		}
+		if len(bases) > 0 {
+			if baseFiles, err = readCommitFiles(bases[0]); err != nil {
+				return nil, err
+			}
+		}
+
+		otherFiles, err := readCommitFiles(other)
+		if err != nil {
+			return nil, err
+		}
+		for filename, entry := range otherFiles {
+			if files[filename] == baseFiles[filename] {
+				files[filename] = entry
+			}
+		}
+	}
+
+	return files, nil
+}
+
+/**
+ * Reconstruit des instantanés complets à partir des commits partiels
+ * Les anciens commits ne contenaient que les fichiers stagés lors de ce commit :
+ * un arbre auquel manquent des fichiers de ses parents est complété avec ces fichiers,
+ * les autres commits sont laissés tels quels.
+ * Les anciennes versions ne permettaient pas de supprimer un fichier, un fichier absent est donc
+ * forcément un oubli ; après la migration, le dépôt est marqué (core.fullSnapshots) et la
+ * commande refuse de s'exécuter à nouveau, car les suppressions seraient alors annulées.
+ */
+func RebuildSnapshots() error {
+	if done, _ := config.Get(repository.FullSnapshotsKey); done == "true" {
+		return fmt.Errorf("commits already are full snapshots (%s = true), nothing to migrate", repository.FullSnapshotsKey)
+	}
+
+	rewriter := newHistoryRewriter(func(hash string, commit *objects.Commit) error {
+		if len(commit.Parents) == 0 {
+			return nil
+		}
+		files, err := objects.ReadTreeFiles(commit.Tree)
+		if err != nil {
+			return err
+		}
+		snapshot, err := parentsSnapshot(commit.Parents)
+		if err != nil {
+			return err
+		}
+
+		partial := false
+		for filename, entry := range snapshot {
+			if _, ok := files[filename]; !ok {
+				files[filename] = entry
+				partial = true
+			}
+		}
+		if !partial {
+			return nil
+		}
+
+		treeHash, err := objects.WriteTree(files)
+		if err != nil {
+			return err
+		}
+		commit.Tree = treeHash
+		return nil
+	})
+
+	updatedRefs, err := rewriteAllRefs(rewriter)
+	if err != nil {
+		return err
+	}
+
+	// Les anciennes versions ne gardaient dans l'index que les fichiers stagés :
+	// l'index est reconstruit depuis HEAD, les fichiers stagés restent par-dessus
+	headFiles := make(map[string]objects.FileEntry)
+	if headHash, err := repository.GetCurrentCommitHash(); err == nil && headHash != "" {
+		if headFiles, err = objects.ReadCommitFiles(headHash); err != nil {
+			return err
+		}
+	}
+	stagedFiles, err := index.ReadFiles()
+	if err != nil {
+		return err
+	}
+	for filename, entry := range stagedFiles {
+		headFiles[filename] = entry
+	}
+	if err := index.WriteEntries(headFiles); err != nil {
+		return fmt.Errorf("failed to rebuild index: %v", err)
+	}
+	if err := config.Set(repository.FullSnapshotsKey, "true", false); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rewrote %d commit(s), updated %d reference(s)\n", rewriter.changedCount(), updatedRefs)
+	return nil
+}
+
+/**
+ * Convertit les anciens objets sans en-tête au format typé "<type> <taille>\0"
+ * Les blobs changent de hash : les trees, les commits, les références et l'index sont réécrits
+ */
+func ConvertObjects() error {
+	converted := make(map[string]string)
+	rewriter := newHistoryRewriter(func(hash string, commit *objects.Commit) error {
+		treeHash, err := convertTree(commit.Tree, converted)
+		if err != nil {
+			return err
+		}
+		commit.Tree = treeHash
+		return nil
+	})
+
+	updatedRefs, err := rewriteAllRefs(rewriter)
+	if err != nil {
+		return err
+	}
+
+	files, err := index.ReadFiles()
+	if err != nil {
+		return err
+	}
+	for filename, entry := range files {
+		if entry.Hash, err = convertBlob(entry.Hash, converted); err != nil {
+			return err
+		}
+		files[filename] = entry
+	}
+	// Pendant un merge, les étapes 1 à 3 des fichiers en conflit sont converties et conservées
+	conflicts, err := index.ReadConflicts()
+	if err != nil {
+		return err
+	}
+	for _, conflict := range conflicts {
+		for _, entry := range []*objects.FileEntry{conflict.Base, conflict.Ours, conflict.Theirs} {
+			if entry == nil {
+				continue
+			}
+			if entry.Hash, err = convertBlob(entry.Hash, converted); err != nil {
+				return err
+			}
+		}
+	}
+	if err := index.WriteEntriesWithConflicts(files, conflicts); err != nil {
+		return fmt.Errorf("failed to rewrite index: %v", err)
+	}
+
+	fmt.Printf("Rewrote %d commit(s), updated %d reference(s)\n", rewriter.changedCount(), updatedRefs)
+	return nil
+}
+
+/**
+ * Réécrit un tree et ses sous-trees avec des blobs au format typé
+ * converted associe chaque ancien hash à son nouveau hash
+ */
+func convertTree(treeHash string, converted map[string]string) (string, error) {
+	if newHash, ok := converted[treeHash]; ok {
+		return newHash, nil
+	}
+
+	entries, err := objects.ReadTree(treeHash)
+	if err != nil {
+		return "", err
+	}
+
+	tree := &objects.Tree{}
+	for _, entry := range entries {
+		if entry.Type == objects.TypeTree {
+			entry.Hash, err = convertTree(entry.Hash, converted)
+		} else {
+			entry.Hash, err = convertBlob(entry.Hash, converted)
+		}
+		if err != nil {
+			return "", err
+		}
+		tree.Entries = append(tree.Entries, entry)
+	}
+
+	newHash, err := objects.Store().Write(tree)
+	if err != nil {
+		return "", err
+	}
+	converted[treeHash] = newHash
+	return newHash, nil
+}
+
+func convertBlob(blobHash string, converted map[string]string) (string, error) {
+	if newHash, ok := converted[blobHash]; ok {
+		return newHash, nil
+	}
+
+	data, err := objects.ReadBlob(blobHash)
+	if err != nil {
+		return "", err
+	}
+	newHash, err := objects.WriteBlob(data)
+	if err != nil {
+		return "", err
+	}
+	converted[blobHash] = newHash
+	return newHash, nil
+}
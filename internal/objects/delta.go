@@ -0,0 +1,152 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/**
+ * Format d'un delta : "<taille base> <taille cible>" (varints) suivi d'instructions
+ * - deltaInsert <longueur> <octets> : ajoute des octets littéraux
+ * - deltaCopy <offset> <longueur> : recopie une portion de la base
+ */
+const (
+	deltaInsert byte = 0
+	deltaCopy   byte = 1
+
+	// Taille des blocs indexés dans la base pour trouver les portions communes
+	deltaBlockSize = 16
+)
+
+/**
+ * Calcule le delta permettant de reconstruire target à partir de base
+ * Retourne nil si la base est trop petite pour servir de référence
+ */
+func createDelta(base, target []byte) []byte {
+	if len(base) < deltaBlockSize {
+		return nil
+	}
+
+	// Indexer les blocs de la base (premier offset pour chaque bloc)
+	blocks := make(map[string]int)
+	for offset := 0; offset+deltaBlockSize <= len(base); offset += deltaBlockSize {
+		key := string(base[offset : offset+deltaBlockSize])
+		if _, exists := blocks[key]; !exists {
+			blocks[key] = offset
+		}
+	}
+
+	delta := binary.AppendUvarint(nil, uint64(len(base)))
+	delta = binary.AppendUvarint(delta, uint64(len(target)))
+
+	var literal []byte
+	flushLiteral := func() {
+		if len(literal) == 0 {
+			return
+		}
+		delta = append(delta, deltaInsert)
+		delta = binary.AppendUvarint(delta, uint64(len(literal)))
+		delta = append(delta, literal...)
+		literal = literal[:0]
+	}
+
+	pos := 0
+	for pos < len(target) {
+		offset, found := -1, false
+		if pos+deltaBlockSize <= len(target) {
+			offset, found = blocks[string(target[pos:pos+deltaBlockSize])]
+		}
+		if !found {
+			literal = append(literal, target[pos])
+			pos++
+			continue
+		}
+
+		// Étendre la correspondance vers l'arrière (sur les octets littéraux en attente) puis vers l'avant
+		for len(literal) > 0 && offset > 0 && base[offset-1] == literal[len(literal)-1] {
+			literal = literal[:len(literal)-1]
+			offset--
+			pos--
+		}
+		length := 0
+		for offset+length < len(base) && pos+length < len(target) && base[offset+length] == target[pos+length] {
+			length++
+		}
+
+		flushLiteral()
+		delta = append(delta, deltaCopy)
+		delta = binary.AppendUvarint(delta, uint64(offset))
+		delta = binary.AppendUvarint(delta, uint64(length))
+		pos += length
+	}
+	flushLiteral()
+
+	return delta
+}
+
+/**
+ * Reconstruit un objet à partir de sa base et d'un delta
+ */
+func applyDelta(base, delta []byte) ([]byte, error) {
+	reader := bytes.NewReader(delta)
+
+	baseSize, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt delta: %v", err)
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("corrupt delta: base size %d, expected %d", len(base), baseSize)
+	}
+	targetSize, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt delta: %v", err)
+	}
+	// Chaque instruction de copie (3 octets au moins) recopie au plus toute la base :
+	// une taille annoncée au-delà ne peut venir que d'un delta corrompu
+	maxTarget := uint64(len(delta))
+	if copies := uint64(len(delta) / 3); len(base) > 0 && copies > 0 {
+		if copies > (math.MaxUint64-maxTarget)/uint64(len(base)) {
+			maxTarget = math.MaxUint64
+		} else {
+			maxTarget += copies * uint64(len(base))
+		}
+	}
+	if targetSize > maxTarget {
+		return nil, fmt.Errorf("corrupt delta: target size %d larger than the delta can produce", targetSize)
+	}
+
+	// La taille annoncée ne sert pas à allouer : target grandit au fil des instructions
+	target := []byte{}
+	for reader.Len() > 0 {
+		op, _ := reader.ReadByte()
+		switch op {
+		case deltaInsert:
+			length, err := binary.ReadUvarint(reader)
+			if err != nil || length > uint64(reader.Len()) {
+				return nil, fmt.Errorf("corrupt delta: invalid insert")
+			}
+			literal := make([]byte, length)
+			reader.Read(literal)
+			target = append(target, literal...)
+		case deltaCopy:
+			offset, err1 := binary.ReadUvarint(reader)
+			length, err2 := binary.ReadUvarint(reader)
+			if err1 != nil || err2 != nil || offset > uint64(len(base)) || length > uint64(len(base))-offset {
+				return nil, fmt.Errorf("corrupt delta: invalid copy")
+			}
+			target = append(target, base[offset:offset+length]...)
+		default:
+			return nil, fmt.Errorf("corrupt delta: unknown instruction %d", op)
+		}
+		if uint64(len(target)) > targetSize {
+			return nil, fmt.Errorf("corrupt delta: result larger than %d bytes", targetSize)
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf("corrupt delta: result size %d, expected %d", len(target), targetSize)
+	}
+	return target, nil
+}
@@ -0,0 +1,84 @@
+package objects
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	base := strings.Repeat("0123456789abcdef", 4) + "tail of the base\n"
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"identical", base},
+		{"insert at start", "header\n" + base},
+		{"insert at end", base + "footer\n"},
+		{"copy up to the last byte of the base", "x" + base[len(base)-deltaBlockSize:]},
+		{"empty target", ""},
+	}
+	for _, tt := range tests {
+		delta := createDelta([]byte(base), []byte(tt.target))
+		got, err := applyDelta([]byte(base), delta)
+		if err != nil || string(got) != tt.target {
+			t.Errorf("%s: rebuilt %q (%v), want %q", tt.name, got, err, tt.target)
+		}
+	}
+
+	if delta := createDelta([]byte(base[:deltaBlockSize-1]), []byte(base)); delta != nil {
+		t.Errorf("delta created against a base smaller than a block")
+	}
+}
+
+func TestApplyDeltaLimits(t *testing.T) {
+	base := []byte("0123456789")
+	header := binary.AppendUvarint(binary.AppendUvarint(nil, 10), 4)
+	withHeader := func(instruction ...byte) []byte {
+		return append(append([]byte{}, header...), instruction...)
+	}
+
+	tests := []struct {
+		name  string
+		delta []byte
+		ok    bool
+	}{
+		{"copy to the end of the base", withHeader(deltaCopy, 6, 4), true},
+		{"copy past the end of the base", withHeader(deltaCopy, 7, 4), false},
+		{"insert of the last bytes", withHeader(deltaInsert, 4, 'a', 'b', 'c', 'd'), true},
+		{"insert longer than the delta", withHeader(deltaInsert, 5, 'a', 'b', 'c', 'd'), false},
+		{"result shorter than announced", withHeader(deltaCopy, 0, 3), false},
+		{"result longer than announced", withHeader(deltaCopy, 0, 5), false},
+	}
+	for _, tt := range tests {
+		if _, err := applyDelta(base, tt.delta); (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+		}
+	}
+}
+
+func TestApplyDeltaHugeVarints(t *testing.T) {
+	base := []byte("0123456789")
+	huge := binary.AppendUvarint(nil, 1<<64-1)
+	build := func(parts ...[]byte) []byte {
+		var delta []byte
+		for _, part := range parts {
+			delta = append(delta, part...)
+		}
+		return delta
+	}
+	header := binary.AppendUvarint(nil, 10)
+
+	tests := map[string][]byte{
+		"huge target size": build(header, huge, []byte{deltaInsert, 1, 'a'}),
+		// offset+length déborde et retombe sous la taille de la base
+		"copy wrapping around": build(header, binary.AppendUvarint(nil, 4), []byte{deltaCopy}, huge, binary.AppendUvarint(nil, 5)),
+		"huge insert length":   build(header, binary.AppendUvarint(nil, 4), []byte{deltaInsert}, huge),
+	}
+	for name, delta := range tests {
+		if _, err := applyDelta(base, delta); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -1,45 +1,406 @@
 package objects
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"fmt"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 /**
- * Calcule le hash SHA1 d'un contenu
+ * Types d'objets stockés dans .goit/objects
  */
-func HashContent(content string) string {
-	hash := sha1.Sum([]byte(content))
+const (
+	TypeBlob   = "blob"
+	TypeTree   = "tree"
+	TypeCommit = "commit"
+)
+
+/**
+ * Un objet du dépôt : son type et son contenu (sans en-tête)
+ */
+type Object interface {
+	Type() string
+	Content() []byte
+}
+
+/**
+ * Contenu d'un fichier (ou cible d'un lien symbolique)
+ */
+type Blob struct {
+	Data []byte
+}
+
+/**
+ * Contenu d'un répertoire
+ */
+type Tree struct {
+	Entries []TreeEntry
+}
+
+/**
+ * Un commit : tree, parents (premier parent en tête), auteur, committer et message
+ * Date n'est renseignée que pour les commits écrits par les anciennes versions, sans auteur
+ */
+type Commit struct {
+	Tree      string
+	Parents   []string
+	Author    *Signature
+	Committer *Signature
+	Date      string
+	Message   string
+}
+
+func (b *Blob) Type() string { return TypeBlob }
+
+func (b *Blob) Content() []byte { return b.Data }
+
+func (t *Tree) Type() string { return TypeTree }
+
+/**
+ * Une ligne par entrée : "<mode> <type> <hash>\t<nom>"
+ * Les entrées sont toujours écrites dans l'ordre canonique, un même répertoire a donc toujours le même hash
+ */
+func (t *Tree) Content() []byte {
+	entries := append([]TreeEntry(nil), t.Entries...)
+	sortTreeEntries(entries)
+
+	var content strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&content, "%s %s %s\t%s\n", entry.Mode, entry.Type, entry.Hash, entry.Name)
+	}
+	return []byte(content.String())
+}
+
+func (c *Commit) Type() string { return TypeCommit }
+
+func (c *Commit) Content() []byte {
+	var content strings.Builder
+	fmt.Fprintf(&content, "tree %s\n", c.Tree)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&content, "parent %s\n", parent)
+	}
+	if c.Author != nil {
+		fmt.Fprintf(&content, "author %s\n", c.Author)
+	}
+	if c.Committer != nil {
+		fmt.Fprintf(&content, "committer %s\n", c.Committer)
+	}
+	if c.Date != "" {
+		fmt.Fprintf(&content, "date %s\n", c.Date)
+	}
+	fmt.Fprintf(&content, "\n%s", c.Message)
+	return []byte(content.String())
+}
+
+/**
+ * Première ligne du message
+ */
+func (c *Commit) Title() string {
+	title, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return title
+}
+
+/**
+ * Date du commit : celle de l'auteur, ou l'ancien champ date
+ */
+func (c *Commit) Time() (time.Time, bool) {
+	if c.Author != nil {
+		return c.Author.When, true
+	}
+	t, err := time.Parse(time.RFC3339, c.Date)
+	return t, err == nil
+}
+
+/**
+ * Sérialise un objet avec son en-tête "<type> <taille>\0"
+ */
+func Encode(obj Object) []byte {
+	content := obj.Content()
+	header := fmt.Sprintf("%s %d\x00", obj.Type(), len(content))
+	return append([]byte(header), content...)
+}
+
+/**
+ * Calcule le hash SHA1 d'un objet (en-tête compris)
+ */
+func Hash(obj Object) string {
+	hash := sha1.Sum(Encode(obj))
 	return fmt.Sprintf("%x", hash[:])
 }
 
-func CreateTree() string {
-	indexContent, err := os.ReadFile(".goit/index")
+/**
+ * Calcule le hash qu'aurait le blob d'un contenu, sans l'écrire
+ */
+func HashBlob(data []byte) string {
+	return Hash(&Blob{Data: data})
+}
+
+/**
+ * Hash d'un blob écrit par une ancienne version : SHA1 du contenu seul, sans en-tête
+ * Les index et les arbres créés avant goit migrate objects contiennent encore ces hashes
+ */
+func LegacyBlobHash(data []byte) string {
+	hash := sha1.Sum(data)
+	return fmt.Sprintf("%x", hash[:])
+}
+
+/**
+ * Indique si une entrée à l'ancien format désigne le même contenu qu'un fichier du répertoire
+ * de travail (working et content viennent de ReadWorkingFile)
+ */
+func IsLegacyMatch(entry, working FileEntry, content []byte) bool {
+	return entry.Mode == working.Mode && entry.Hash != working.Hash && entry.Hash == LegacyBlobHash(content)
+}
+
+/**
+ * Désérialise un objet lu depuis le stockage
+ * Les anciens objets sans en-tête sont retournés comme des blobs bruts :
+ * ReadTree() et ReadCommit() savent les interpréter selon le type attendu
+ */
+func Decode(data []byte) (Object, error) {
+	objType, content, ok := splitHeader(data)
+	if !ok {
+		return &Blob{Data: data}, nil
+	}
+
+	switch objType {
+	case TypeBlob:
+		return &Blob{Data: content}, nil
+	case TypeTree:
+		return parseTree(content)
+	case TypeCommit:
+		return parseCommit(content), nil
+	}
+	return nil, fmt.Errorf("unknown object type %q", objType)
+}
+
+/**
+ * Sépare l'en-tête "<type> <taille>\0" du contenu
+ * Retourne false si l'objet n'a pas d'en-tête valide (ancien format)
+ */
+func splitHeader(data []byte) (string, []byte, bool) {
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 || nul > 32 {
+		return "", nil, false
+	}
+
+	objType, size, found := strings.Cut(string(data[:nul]), " ")
+	if !found {
+		return "", nil, false
+	}
+	if objType != TypeBlob && objType != TypeTree && objType != TypeCommit {
+		return "", nil, false
+	}
+
+	content := data[nul+1:]
+	if n, err := strconv.Atoi(size); err != nil || n != len(content) {
+		return "", nil, false
+	}
+	return objType, content, true
+}
+
+func parseTree(content []byte) (*Tree, error) {
+	tree := &Tree{}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+		meta, name, found := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !found || len(fields) != 3 {
+			return nil, fmt.Errorf("malformed tree entry: %q", line)
+		}
+		tree.Entries = append(tree.Entries, TreeEntry{Mode: fields[0], Type: fields[1], Hash: fields[2], Name: name})
+	}
+	return tree, nil
+}
+
+func parseCommit(content []byte) *Commit {
+	commit := &Commit{}
+	header, message, _ := strings.Cut(string(content), "\n\n")
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			commit.Author, _ = parseSignature(value)
+		case "committer":
+			commit.Committer, _ = parseSignature(value)
+		case "date":
+			commit.Date = value
+		}
+	}
+	commit.Message = message
+	return commit
+}
+
+/**
+ * Parse un ancien tree sans en-tête
+ * Format hiérarchique "tree\n<mode> <type> <hash>\t<nom>" ou format plat "tree\n<hash> <chemin>"
+ * Les fichiers du format plat sont retournés séparément
+ */
+func parseLegacyTree(data []byte) ([]TreeEntry, map[string]FileEntry, error) {
+	if !bytes.HasPrefix(data, []byte("tree\n")) {
+		return nil, nil, fmt.Errorf("object is not a tree")
+	}
+
+	var entries []TreeEntry
+	flatFiles := make(map[string]FileEntry)
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		if line == "" {
+			continue
+		}
+
+		meta, name, hierarchical := strings.Cut(line, "\t")
+		if !hierarchical {
+			parts := strings.SplitN(line, " ", 2)
+			if len(parts) == 2 {
+				flatFiles[parts[1]] = FileEntry{Mode: ModeFile, Hash: parts[0]}
+			}
+			continue
+		}
+
+		fields := strings.Fields(meta)
+		if len(fields) != 3 {
+			return nil, nil, fmt.Errorf("malformed tree entry: %q", line)
+		}
+		entries = append(entries, TreeEntry{Mode: fields[0], Type: fields[1], Hash: fields[2], Name: name})
+	}
+	return entries, flatFiles, nil
+}
+
+/**
+ * Parse un ancien commit sans en-tête ("commit\n tree ...\n parent ...\n date ...\n\n message")
+ * Gère aussi les anciens commits de merge dont le second parent était inséré après la date
+ * et avant chaque ligne vide du message
+ */
+func parseLegacyCommit(data []byte) (*Commit, error) {
+	if !bytes.HasPrefix(data, []byte("commit\n")) {
+		return nil, fmt.Errorf("object is not a commit")
+	}
+
+	commit := &Commit{}
+	header, message, _ := strings.Cut(string(data), "\n\n")
+	var lateParents []string
+	for _, line := range strings.Split(header, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "tree "):
+			commit.Tree = strings.TrimPrefix(trimmed, "tree ")
+		case strings.HasPrefix(trimmed, "parent "):
+			parent := strings.TrimPrefix(trimmed, "parent ")
+			commit.Parents = append(commit.Parents, parent)
+			if commit.Date != "" {
+				lateParents = append(lateParents, parent)
+			}
+		case strings.HasPrefix(trimmed, "date "):
+			commit.Date = strings.TrimPrefix(trimmed, "date ")
+		}
+	}
+
+	// Retirer les lignes "parent" insérées à tort dans le message
+	for _, parent := range lateParents {
+		message = strings.ReplaceAll(message, "\nparent "+parent+"\n\n", "\n\n")
+		if strings.HasSuffix(message, "\nparent "+parent+"\n") {
+			message = strings.TrimSuffix(message, "parent "+parent+"\n")
+		}
+	}
+	commit.Message = strings.TrimPrefix(message, " ")
+
+	return commit, nil
+}
+
+/**
+ * Lit le contenu d'un blob
+ */
+func ReadBlob(hash string) ([]byte, error) {
+	obj, err := Store().Read(hash)
+	if err != nil {
+		return nil, err
+	}
+	blob, ok := obj.(*Blob)
+	if !ok {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", hash, obj.Type())
+	}
+	return blob.Data, nil
+}
+
+/**
+ * Écrit un blob et retourne son hash
+ */
+func WriteBlob(data []byte) (string, error) {
+	return Store().Write(&Blob{Data: data})
+}
+
+/**
+ * Lit un commit (ancien format compris)
+ */
+func ReadCommit(hash string) (*Commit, error) {
+	obj, err := Store().Read(hash)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	treeContent := "tree\n" + string(indexContent)
-	hash := sha1.Sum([]byte(treeContent))
-	hashStr := fmt.Sprintf("%x", hash[:])
+	switch o := obj.(type) {
+	case *Commit:
+		return o, nil
+	case *Blob:
+		commit, err := parseLegacyCommit(o.Data)
+		if err != nil {
+			return nil, fmt.Errorf("object %s: %v", hash, err)
+		}
+		return commit, nil
+	}
+	return nil, fmt.Errorf("object %s is a %s, not a commit", hash, obj.Type())
+}
 
-	os.WriteFile(".goit/objects/"+hashStr, []byte(treeContent), 0644)
-	return hashStr
+/**
+ * Crée un commit signé par l'auteur et le committer courants (variables d'environnement ou configuration)
+ * parents contient les hashes des commits parents dans l'ordre (premier parent en tête),
+ * un commit de merge en a plusieurs et le premier commit n'en a aucun
+ */
+func CreateCommit(treeHash string, message string, parents []string) (string, error) {
+	author, err := NewSignature("AUTHOR")
+	if err != nil {
+		return "", err
+	}
+	committer, err := NewSignature("COMMITTER")
+	if err != nil {
+		return "", err
+	}
+	return WriteCommit(&Commit{Tree: treeHash, Parents: parents, Author: author, Committer: committer, Message: message})
 }
 
-func CreateCommit(treeHash string, message string, parentHash string) string {
-	now := time.Now().UTC().Format(time.RFC3339)
-	var content string
-	if parentHash != "" {
-		content = fmt.Sprintf("commit\n tree %s\n parent %s\n date %s\n\n %s", treeHash, parentHash, now, message)
-	} else {
-		content = fmt.Sprintf("commit\n tree %s\n date %s\n\n %s", treeHash, now, message)
+/**
+ * Écrit un objet commit complet en une seule fois
+ * Le contenu est définitif avant le calcul du hash, le nom du fichier correspond donc toujours à son contenu
+ */
+func WriteCommit(commit *Commit) (string, error) {
+	var parents []string
+	for _, parent := range commit.Parents {
+		if parent != "" {
+			parents = append(parents, parent)
+		}
 	}
-	hash := sha1.Sum([]byte(content))
-	hashStr := fmt.Sprintf("%x", hash[:])
+	commit.Parents = parents
+	return Store().Write(commit)
+}
 
-	os.WriteFile(".goit/objects/"+hashStr, []byte(content), 0644)
-	return hashStr
+/**
+ * Trie des noms de fichiers (utilisé pour l'ordre des entrées d'un tree)
+ */
+func sortedNames[T any](entries map[string]T) []string {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,461 @@
+package objects
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Format d'un packfile (objects/pack/pack-<checksum>.pack) :
+ * "PACK" <version> <nombre d'objets> puis les entrées, et le SHA1 de tout ce qui précède
+ * Chaque entrée : <type d'entrée> [<hash de la base>] <taille> <données compressées zlib>
+ * Les données sont l'objet tel qu'il est stocké (en-tête compris) ou un delta par rapport à la base
+ *
+ * Format de l'index (pack-<checksum>.idx) :
+ * "GIDX" <version> <nombre d'objets> puis, triés par hash, <hash (20 octets)> <offset (8 octets)>,
+ * le SHA1 du packfile et le SHA1 de tout ce qui précède dans l'index
+ *
+ * L'ouverture ne fait que des vérifications rapides (en-têtes, tailles, offsets, somme du pack
+ * enregistrée dans l'index) ; les deux sommes de contrôle complètes sont vérifiées par repack
+ */
+const (
+	packMagic      = "PACK"
+	packIdxMagic   = "GIDX"
+	packVersion    = 1
+	packIdxVersion = 2
+
+	packEntryFull  byte = 1
+	packEntryDelta byte = 2
+
+	// Profondeur maximale d'une chaîne de deltas
+	maxDeltaDepth = 10
+	// Nombre d'objets précédents essayés comme base d'un delta
+	deltaWindow = 10
+
+	// Rapport maximal entre données décompressées et compressées avec zlib (deflate)
+	maxInflateRatio = 1032
+)
+
+/**
+ * Un packfile ouvert et sa table d'index
+ */
+type packFile struct {
+	path    string
+	file    *os.File
+	size    int64
+	hashes  []string
+	offsets []int64
+	// Somme de contrôle du packfile enregistrée dans l'index
+	checksum []byte
+}
+
+/**
+ * Un objet à écrire dans un packfile, complet ou sous forme de delta
+ */
+type packEntry struct {
+	hash  string
+	raw   []byte
+	base  string
+	delta []byte
+}
+
+/**
+ * Ouvre un packfile à partir de son fichier d'index
+ */
+func openPack(idxPath string) (*packFile, error) {
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	const headerSize, recordSize = 12, 28
+	if len(data) < headerSize+sha1.Size || string(data[:4]) != packIdxMagic {
+		return nil, fmt.Errorf("invalid pack index %s", idxPath)
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != packIdxVersion {
+		return nil, fmt.Errorf("unsupported pack index version %d in %s", version, idxPath)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	if len(data) != headerSize+count*recordSize+2*sha1.Size {
+		return nil, fmt.Errorf("truncated pack index %s", idxPath)
+	}
+	checksumStart := headerSize + count*recordSize
+
+	pack := &packFile{
+		path:     strings.TrimSuffix(idxPath, ".idx") + ".pack",
+		hashes:   make([]string, count),
+		offsets:  make([]int64, count),
+		checksum: data[checksumStart : checksumStart+sha1.Size],
+	}
+	for i := 0; i < count; i++ {
+		record := data[headerSize+i*recordSize:]
+		pack.hashes[i] = hex.EncodeToString(record[:20])
+		pack.offsets[i] = int64(binary.BigEndian.Uint64(record[20:28]))
+	}
+
+	if pack.file, err = os.Open(pack.path); err != nil {
+		return nil, err
+	}
+	if err := pack.check(count); err != nil {
+		pack.file.Close()
+		return nil, err
+	}
+	return pack, nil
+}
+
+/**
+ * Vérifications rapides à l'ouverture, sans relire tout le packfile :
+ * en-tête, nombre d'objets, somme finale identique à celle de l'index
+ * et offsets de l'index à l'intérieur du pack
+ */
+func (p *packFile) check(count int) error {
+	info, err := p.file.Stat()
+	if err != nil {
+		return err
+	}
+	packSize := info.Size()
+	p.size = packSize
+	if packSize < 12+sha1.Size {
+		return fmt.Errorf("truncated packfile %s", p.path)
+	}
+
+	header := make([]byte, 12)
+	if _, err := p.file.ReadAt(header, 0); err != nil || string(header[:4]) != packMagic {
+		return fmt.Errorf("invalid packfile %s", p.path)
+	}
+	if binary.BigEndian.Uint32(header[8:12]) != uint32(count) {
+		return fmt.Errorf("packfile %s does not match its index", p.path)
+	}
+
+	trailer := make([]byte, sha1.Size)
+	if _, err := p.file.ReadAt(trailer, packSize-sha1.Size); err != nil {
+		return fmt.Errorf("cannot read packfile %s: %v", p.path, err)
+	}
+	if !bytes.Equal(trailer, p.checksum) {
+		return fmt.Errorf("packfile %s does not match its index", p.path)
+	}
+
+	for _, offset := range p.offsets {
+		if offset < 12 || offset >= packSize-sha1.Size {
+			return fmt.Errorf("corrupt pack index for %s: offset %d out of range", p.path, offset)
+		}
+	}
+	return nil
+}
+
+/**
+ * Vérifie les sommes de contrôle complètes : SHA1 de tout le packfile et de l'index
+ * Coûteux (lecture de tout le pack), réservé à repack
+ */
+func (p *packFile) verify() error {
+	idxPath := strings.TrimSuffix(p.path, ".pack") + ".idx"
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return err
+	}
+	end := len(data) - sha1.Size
+	if sum := sha1.Sum(data[:end]); !bytes.Equal(sum[:], data[end:]) {
+		return fmt.Errorf("corrupt pack index %s: checksum mismatch", idxPath)
+	}
+
+	checksum := sha1.New()
+	if _, err := io.Copy(checksum, io.NewSectionReader(p.file, 0, p.size-sha1.Size)); err != nil {
+		return fmt.Errorf("cannot read packfile %s: %v", p.path, err)
+	}
+	if !bytes.Equal(checksum.Sum(nil), p.checksum) {
+		return fmt.Errorf("corrupt packfile %s: checksum mismatch", p.path)
+	}
+	return nil
+}
+
+/**
+ * Cherche un objet dans la table d'index (recherche dichotomique)
+ */
+func (p *packFile) find(hash string) (int64, bool) {
+	i := sort.SearchStrings(p.hashes, hash)
+	if i < len(p.hashes) && p.hashes[i] == hash {
+		return p.offsets[i], true
+	}
+	return 0, false
+}
+
+/**
+ * Lit l'objet stocké à un offset du packfile
+ * readBase permet de récupérer la base d'un delta, où qu'elle soit stockée
+ */
+func (p *packFile) readAt(offset int64, readBase func(hash string) ([]byte, error)) ([]byte, error) {
+	reader := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+
+	kind, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var baseHash string
+	if kind == packEntryDelta {
+		base := make([]byte, 20)
+		if _, err := io.ReadFull(reader, base); err != nil {
+			return nil, err
+		}
+		baseHash = hex.EncodeToString(base)
+	} else if kind != packEntryFull {
+		return nil, fmt.Errorf("unknown pack entry type %d", kind)
+	}
+
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	// La taille vient du pack et ne doit pas faire allouer n'importe quoi :
+	// les données compressées restantes ne peuvent pas produire davantage
+	if size > uint64(p.size-offset)*maxInflateRatio {
+		return nil, fmt.Errorf("corrupt packfile %s: entry at offset %d larger than the pack", p.path, offset)
+	}
+	zr, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	inflated := bufio.NewReader(zr)
+	if kind == packEntryFull {
+		// Un objet avec en-tête annonce sa propre taille, qui doit correspondre à celle de l'entrée
+		header, _ := inflated.Peek(int(min(size, 33)))
+		if declared, ok := declaredSize(header); ok && declared != size {
+			return nil, fmt.Errorf("corrupt packfile %s: entry at offset %d has size %d, object declares %d", p.path, offset, size, declared)
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(inflated, int64(size)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != size {
+		return nil, fmt.Errorf("corrupt packfile %s: entry at offset %d is truncated", p.path, offset)
+	}
+
+	if kind == packEntryFull {
+		return data, nil
+	}
+	base, err := readBase(baseHash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read delta base %s: %v", baseHash, err)
+	}
+	return applyDelta(base, data)
+}
+
+/**
+ * Taille totale (en-tête compris) annoncée par l'en-tête "<type> <taille>\0" d'un objet
+ * Retourne false si le début de l'objet n'est pas un en-tête valide (ancien format)
+ */
+func declaredSize(header []byte) (uint64, bool) {
+	nul := bytes.IndexByte(header, 0)
+	if nul < 0 {
+		return 0, false
+	}
+	objType, size, found := strings.Cut(string(header[:nul]), " ")
+	if !found || (objType != TypeBlob && objType != TypeTree && objType != TypeCommit) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(size, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint64(nul+1) + n, true
+}
+
+func (p *packFile) close() {
+	p.file.Close()
+}
+
+/**
+ * Écrit un packfile et son index dans dir
+ * L'index est écrit en dernier : un pack n'est visible qu'une fois complet
+ * Retourne le chemin du packfile
+ */
+func writePack(dir string, entries []packEntry) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create pack directory %s: %v", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "tmp_pack_")
+	if err != nil {
+		return "", fmt.Errorf("cannot create packfile: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	checksum := sha1.New()
+	writer := &countingWriter{w: io.MultiWriter(tmp, checksum)}
+	offsets := make(map[string]int64, len(entries))
+
+	header := []byte(packMagic)
+	header = binary.BigEndian.AppendUint32(header, packVersion)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(entries)))
+	if _, err := writer.Write(header); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("cannot write packfile: %v", err)
+	}
+
+	for _, entry := range entries {
+		offsets[entry.hash] = writer.n
+		if err := writePackEntry(writer, entry); err != nil {
+			tmp.Close()
+			return "", fmt.Errorf("cannot write packfile: %v", err)
+		}
+	}
+
+	sum := checksum.Sum(nil)
+	if _, err := tmp.Write(sum); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("cannot write packfile: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("cannot write packfile: %v", err)
+	}
+
+	name := filepath.Join(dir, "pack-"+hex.EncodeToString(sum))
+	if err := os.Rename(tmp.Name(), name+".pack"); err != nil {
+		return "", fmt.Errorf("cannot write packfile: %v", err)
+	}
+	if err := writePackIndex(name+".idx", offsets, sum); err != nil {
+		return "", err
+	}
+	return name + ".pack", nil
+}
+
+func writePackEntry(writer io.Writer, entry packEntry) error {
+	var header []byte
+	data := entry.raw
+	if entry.delta != nil {
+		base, err := hex.DecodeString(entry.base)
+		if err != nil || len(base) != 20 {
+			return fmt.Errorf("invalid delta base %q", entry.base)
+		}
+		header = append([]byte{packEntryDelta}, base...)
+		data = entry.delta
+	} else {
+		header = []byte{packEntryFull}
+	}
+	header = binary.AppendUvarint(header, uint64(len(data)))
+
+	if _, err := writer.Write(header); err != nil {
+		return err
+	}
+	zw := zlib.NewWriter(writer)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writePackIndex(path string, offsets map[string]int64, packChecksum []byte) error {
+	var content bytes.Buffer
+	content.WriteString(packIdxMagic)
+	binary.Write(&content, binary.BigEndian, uint32(packIdxVersion))
+	binary.Write(&content, binary.BigEndian, uint32(len(offsets)))
+
+	for _, hash := range sortedNames(offsets) {
+		raw, err := hex.DecodeString(hash)
+		if err != nil || len(raw) != 20 {
+			return fmt.Errorf("invalid object hash %q", hash)
+		}
+		content.Write(raw)
+		binary.Write(&content, binary.BigEndian, uint64(offsets[hash]))
+	}
+	content.Write(packChecksum)
+	indexChecksum := sha1.Sum(content.Bytes())
+	content.Write(indexChecksum[:])
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content.Bytes(), 0444); err != nil {
+		return fmt.Errorf("cannot write pack index: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cannot write pack index: %v", err)
+	}
+	return nil
+}
+
+/**
+ * Writer qui compte les octets écrits (offsets des entrées du pack)
+ */
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+/**
+ * Choisit une base pour chaque objet et calcule les deltas
+ * Les objets sont triés par type, nom de fichier puis taille décroissante :
+ * les versions successives d'un même fichier se retrouvent côte à côte.
+ * Un delta n'est conservé que s'il fait moins de la moitié de l'objet.
+ */
+func deltifyObjects(raws map[string][]byte, nameHints map[string]string) []packEntry {
+	type candidate struct {
+		hash    string
+		objType string
+		name    string
+		depth   int
+	}
+
+	candidates := make([]candidate, 0, len(raws))
+	for hash, raw := range raws {
+		objType, _, ok := splitHeader(raw)
+		if !ok {
+			objType = "legacy"
+		}
+		candidates = append(candidates, candidate{hash: hash, objType: objType, name: filepath.Base(nameHints[hash])})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.objType != b.objType {
+			return a.objType < b.objType
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if len(raws[a.hash]) != len(raws[b.hash]) {
+			return len(raws[a.hash]) > len(raws[b.hash])
+		}
+		return a.hash < b.hash
+	})
+
+	entries := make([]packEntry, 0, len(candidates))
+	for i := range candidates {
+		current := &candidates[i]
+		raw := raws[current.hash]
+		entry := packEntry{hash: current.hash, raw: raw}
+
+		for j := max(0, i-deltaWindow); j < i; j++ {
+			base := candidates[j]
+			if base.objType != current.objType || base.depth >= maxDeltaDepth {
+				continue
+			}
+			delta := createDelta(raws[base.hash], raw)
+			if delta == nil || len(delta) >= len(raw)/2 {
+				continue
+			}
+			if entry.delta == nil || len(delta) < len(entry.delta) {
+				entry.base, entry.delta = base.hash, delta
+				current.depth = base.depth + 1
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,118 @@
+package objects
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackRoundTripAndChecks(t *testing.T) {
+	raws := make(map[string][]byte)
+	hints := make(map[string]string)
+	content := strings.Repeat("a line that stays the same in every version\n", 20)
+	for i := 0; i < 3; i++ {
+		content += fmt.Sprintf("line added by version %d\n", i)
+		raw := Encode(&Blob{Data: []byte(content)})
+		hash := LegacyBlobHash(raw)
+		raws[hash], hints[hash] = raw, "file.txt"
+	}
+
+	packPath, err := writePack(t.TempDir(), deltifyObjects(raws, hints))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+
+	pack, err := openPack(idxPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var readRaw func(hash string) ([]byte, error)
+	readRaw = func(hash string) ([]byte, error) {
+		offset, _ := pack.find(hash)
+		return pack.readAt(offset, readRaw)
+	}
+	for hash, raw := range raws {
+		if got, err := readRaw(hash); err != nil || string(got) != string(raw) {
+			t.Errorf("%s: read %q (%v)", hash, got, err)
+		}
+	}
+	if err := pack.verify(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	pack.close()
+
+	// Un octet modifié n'est détecté que par verify, une troncature dès l'ouverture
+	data, _ := os.ReadFile(packPath)
+	data[20] ^= 0xff
+	os.WriteFile(packPath, data, 0644)
+	if pack, err = openPack(idxPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := pack.verify(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("verify of a corrupted pack: %v", err)
+	}
+	pack.close()
+
+	os.WriteFile(packPath, data[:len(data)-1], 0644)
+	if _, err := openPack(idxPath); err == nil {
+		t.Error("truncated pack was opened")
+	}
+}
+
+func TestPackRejectsCorruptEntrySize(t *testing.T) {
+	raw := Encode(&Blob{Data: []byte("content\n")})
+	hash := LegacyBlobHash(raw)
+	packPath, err := writePack(t.TempDir(), []packEntry{{hash: hash, raw: raw}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+	data, _ := os.ReadFile(packPath)
+
+	// Entrée unique à l'offset 12 : type, taille (un octet), données compressées
+	sizes := map[string][]byte{
+		"huge":     binary.AppendUvarint(nil, 1<<40),
+		"declared": binary.AppendUvarint(nil, uint64(len(raw)-1)),
+	}
+	for name, size := range sizes {
+		corrupt := append(append(append([]byte{}, data[:13]...), size...), data[14:]...)
+		os.WriteFile(packPath, corrupt, 0644)
+		pack, err := openPack(idxPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pack.readAt(12, nil); err == nil || !strings.Contains(err.Error(), "corrupt packfile") {
+			t.Errorf("%s: read %v", name, err)
+		}
+		pack.close()
+	}
+}
+
+func TestPackRejectsDeltaCycles(t *testing.T) {
+	raw := Encode(&Blob{Data: []byte(strings.Repeat("content of the object\n", 4))})
+	hash := LegacyBlobHash(raw)
+	other := strings.Repeat("ab", 20)
+
+	tests := map[string][]packEntry{
+		"delta on itself": {{hash: hash, base: hash, delta: createDelta(raw, raw)}},
+		"cycle of deltas": {
+			{hash: hash, base: other, delta: createDelta(raw, raw)},
+			{hash: other, base: hash, delta: createDelta(raw, raw)},
+		},
+	}
+	for name, entries := range tests {
+		dir := t.TempDir()
+		if _, err := writePack(filepath.Join(dir, "pack"), entries); err != nil {
+			t.Fatal(err)
+		}
+		store := NewPackedStore(dir)
+		if _, err := store.readRaw(hash); err == nil || !strings.Contains(err.Error(), "delta chain") {
+			t.Errorf("%s: read %v", name, err)
+		}
+		store.closePacks()
+	}
+}
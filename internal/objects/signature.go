@@ -0,0 +1,111 @@
+package objects
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"projet-go-git/internal/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * Identité de l'auteur ou du committer d'un commit, avec la date et le fuseau horaire
+ */
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+/**
+ * Format stocké dans le commit : "Nom <email> <timestamp unix> <+hhmm>"
+ */
+func (s *Signature) String() string {
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), s.When.Format("-0700"))
+}
+
+func parseSignature(value string) (*Signature, error) {
+	open := strings.LastIndex(value, "<")
+	closing := strings.LastIndex(value, ">")
+	if open < 0 || closing < open {
+		return nil, fmt.Errorf("malformed signature: %q", value)
+	}
+
+	when, err := parseSignatureTime(strings.TrimSpace(value[closing+1:]))
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %q", value)
+	}
+	return &Signature{
+		Name:  strings.TrimSpace(value[:open]),
+		Email: value[open+1 : closing],
+		When:  when,
+	}, nil
+}
+
+/**
+ * Parse "<timestamp unix> <+hhmm>" en conservant le fuseau horaire d'origine
+ */
+func parseSignatureTime(value string) (time.Time, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	zone, err := time.Parse("-0700", fields[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0).In(zone.Location()), nil
+}
+
+/**
+ * Construit la signature de l'auteur ("AUTHOR") ou du committer ("COMMITTER")
+ * Ordre de priorité : variables GOIT_<ROLE>_NAME, GOIT_<ROLE>_EMAIL et GOIT_<ROLE>_DATE,
+ * puis user.name et user.email de la configuration, puis l'utilisateur du système
+ */
+func NewSignature(role string) (*Signature, error) {
+	name := os.Getenv("GOIT_" + role + "_NAME")
+	if name == "" {
+		name, _ = config.Get("user.name")
+	}
+	email := os.Getenv("GOIT_" + role + "_EMAIL")
+	if email == "" {
+		email, _ = config.Get("user.email")
+	}
+
+	if name == "" || email == "" {
+		login := "unknown"
+		if current, err := user.Current(); err == nil && current.Username != "" {
+			login = current.Username
+		}
+		if name == "" {
+			name = login
+		}
+		if email == "" {
+			host, err := os.Hostname()
+			if err != nil || host == "" {
+				host = "localhost"
+			}
+			email = login + "@" + host
+		}
+	}
+
+	when := time.Now()
+	if date := os.Getenv("GOIT_" + role + "_DATE"); date != "" {
+		var err error
+		if when, err = time.Parse(time.RFC3339, date); err != nil {
+			if when, err = parseSignatureTime(date); err != nil {
+				return nil, fmt.Errorf("invalid GOIT_%s_DATE %q", role, date)
+			}
+		}
+	}
+
+	// Les chevrons et retours à la ligne casseraient le format du commit
+	clean := strings.NewReplacer("<", "", ">", "", "\n", " ")
+	return &Signature{Name: clean.Replace(name), Email: clean.Replace(email), When: when.Truncate(time.Second)}, nil
+}
@@ -0,0 +1,464 @@
+package objects
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/**
+ * Accès centralisé aux objets du dépôt
+ */
+type ObjectStore interface {
+	Read(hash string) (Object, error)
+	Write(obj Object) (string, error)
+	Has(hash string) bool
+}
+
+/**
+ * Stockage des objets sous forme de fichiers individuels compressés avec zlib
+ * Chaque objet est rangé dans un sous-répertoire nommé d'après les deux premiers
+ * caractères de son hash (objects/ab/cdef...), l'ancien format plat non compressé
+ * (objects/abcdef...) reste lisible
+ */
+type LooseStore struct {
+	dir string
+}
+
+func NewLooseStore(dir string) *LooseStore {
+	return &LooseStore{dir: dir}
+}
+
+var defaultStore = NewPackedStore(filepath.Join(".goit", "objects"))
+
+/**
+ * Retourne le stockage d'objets du dépôt courant
+ */
+func Store() ObjectStore {
+	return defaultStore
+}
+
+func (s *LooseStore) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash[2:])
+}
+
+func (s *LooseStore) legacyPath(hash string) string {
+	return filepath.Join(s.dir, hash)
+}
+
+func (s *LooseStore) Read(hash string) (Object, error) {
+	data, err := s.readRaw(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode object %s: %v", hash, err)
+	}
+	return obj, nil
+}
+
+/**
+ * Lit un objet tel qu'il est stocké (en-tête compris, décompressé)
+ */
+func (s *LooseStore) readRaw(hash string) ([]byte, error) {
+	if len(hash) < 3 {
+		return nil, fmt.Errorf("invalid object hash %q", hash)
+	}
+
+	data, err := s.readCompressed(hash)
+	if os.IsNotExist(err) {
+		// Ancien format : fichier plat non compressé
+		data, err = os.ReadFile(s.legacyPath(hash))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read object %s: %v", hash, err)
+	}
+	return data, nil
+}
+
+func (s *LooseStore) readCompressed(hash string) ([]byte, error) {
+	f, err := os.Open(s.path(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := zlib.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+/**
+ * Écrit un objet s'il n'existe pas déjà et retourne son hash
+ * L'objet est écrit dans un fichier temporaire puis renommé pour ne jamais laisser d'objet partiel
+ */
+func (s *LooseStore) Write(obj Object) (string, error) {
+	hash := Hash(obj)
+	if s.Has(hash) {
+		return hash, nil
+	}
+
+	dir := filepath.Dir(s.path(hash))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create object directory %s: %v", dir, err)
+	}
+
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	if _, err := w.Write(Encode(obj)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, "tmp_obj_")
+	if err != nil {
+		return "", fmt.Errorf("cannot write object %s: %v", hash, err)
+	}
+	if _, err := tmp.Write(compressed.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("cannot write object %s: %v", hash, err)
+	}
+	tmp.Close()
+	os.Chmod(tmp.Name(), 0444)
+
+	if err := os.Rename(tmp.Name(), s.path(hash)); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("cannot write object %s: %v", hash, err)
+	}
+	return hash, nil
+}
+
+func (s *LooseStore) Has(hash string) bool {
+	if len(hash) < 3 {
+		return false
+	}
+	if _, err := os.Stat(s.path(hash)); err == nil {
+		return true
+	}
+	_, err := os.Stat(s.legacyPath(hash))
+	return err == nil
+}
+
+/**
+ * Liste les hashes de tous les objets stockés individuellement (les deux formats)
+ */
+func (s *LooseStore) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			if isObjectHash(name) {
+				hashes = append(hashes, name)
+			}
+			continue
+		}
+		if len(name) != 2 || !isObjectHash(name+strings.Repeat("0", 38)) {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if hash := name + file.Name(); isObjectHash(hash) {
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	return hashes, nil
+}
+
+/**
+ * Supprime un objet stocké individuellement (les deux formats)
+ * Le sous-répertoire est supprimé s'il devient vide
+ */
+func (s *LooseStore) remove(hash string) {
+	os.Remove(s.legacyPath(hash))
+	if os.Remove(s.path(hash)) == nil {
+		os.Remove(filepath.Dir(s.path(hash)))
+	}
+}
+
+func isObjectHash(name string) bool {
+	if len(name) != 40 {
+		return false
+	}
+	for _, c := range name {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Stockage complet du dépôt : packfiles (objects/pack) et objets individuels
+ * Les packfiles sont consultés en premier, les nouveaux objets sont écrits individuellement
+ * jusqu'au prochain repack
+ */
+type PackedStore struct {
+	dir   string
+	loose *LooseStore
+	packs []*packFile
+	// Les packfiles ne sont ouverts qu'au premier accès
+	packsLoaded bool
+}
+
+func NewPackedStore(dir string) *PackedStore {
+	return &PackedStore{dir: dir, loose: NewLooseStore(dir)}
+}
+
+func (s *PackedStore) packDir() string {
+	return filepath.Join(s.dir, "pack")
+}
+
+func (s *PackedStore) loadPacks() error {
+	if s.packsLoaded {
+		return nil
+	}
+
+	idxFiles, err := filepath.Glob(filepath.Join(s.packDir(), "pack-*.idx"))
+	if err != nil {
+		return err
+	}
+	for _, idxFile := range idxFiles {
+		pack, err := openPack(idxFile)
+		if err != nil {
+			return err
+		}
+		s.packs = append(s.packs, pack)
+	}
+	s.packsLoaded = true
+	return nil
+}
+
+/**
+ * Ferme les packfiles ouverts, ils seront relus au prochain accès
+ */
+func (s *PackedStore) closePacks() {
+	for _, pack := range s.packs {
+		pack.close()
+	}
+	s.packs = nil
+	s.packsLoaded = false
+}
+
+func (s *PackedStore) Read(hash string) (Object, error) {
+	data, err := s.readRaw(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode object %s: %v", hash, err)
+	}
+	return obj, nil
+}
+
+func (s *PackedStore) readRaw(hash string) ([]byte, error) {
+	return s.readRawAtDepth(hash, 0)
+}
+
+/**
+ * Lit un objet situé à depth niveaux d'une chaîne de deltas
+ * repack n'écrit pas de chaîne plus longue que maxDeltaDepth : au-delà, le pack est corrompu
+ * (delta dont la base est lui-même, cycle de deltas)
+ */
+func (s *PackedStore) readRawAtDepth(hash string, depth int) ([]byte, error) {
+	if err := s.loadPacks(); err != nil {
+		return nil, fmt.Errorf("cannot load packfiles: %v", err)
+	}
+	for _, pack := range s.packs {
+		if offset, found := pack.find(hash); found {
+			if depth > maxDeltaDepth {
+				return nil, fmt.Errorf("corrupt pack %s: delta chain of %s longer than %d", filepath.Base(pack.path), hash, maxDeltaDepth)
+			}
+			data, err := pack.readAt(offset, func(base string) ([]byte, error) {
+				return s.readRawAtDepth(base, depth+1)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cannot read object %s from %s: %v", hash, filepath.Base(pack.path), err)
+			}
+			return data, nil
+		}
+	}
+	return s.loose.readRaw(hash)
+}
+
+func (s *PackedStore) Write(obj Object) (string, error) {
+	hash := Hash(obj)
+	if s.inPack(hash) {
+		return hash, nil
+	}
+	return s.loose.Write(obj)
+}
+
+func (s *PackedStore) Has(hash string) bool {
+	return s.inPack(hash) || s.loose.Has(hash)
+}
+
+func (s *PackedStore) inPack(hash string) bool {
+	if s.loadPacks() != nil {
+		return false
+	}
+	for _, pack := range s.packs {
+		if _, found := pack.find(hash); found {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Liste les hashes des objets qui commencent par un préfixe (packfiles et objets individuels)
+ */
+func (s *PackedStore) HashesWithPrefix(prefix string) ([]string, error) {
+	if err := s.loadPacks(); err != nil {
+		return nil, fmt.Errorf("cannot load packfiles: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, pack := range s.packs {
+		for i := sort.SearchStrings(pack.hashes, prefix); i < len(pack.hashes) && strings.HasPrefix(pack.hashes[i], prefix); i++ {
+			found[pack.hashes[i]] = true
+		}
+	}
+
+	looseHashes, err := s.loose.list()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list objects: %v", err)
+	}
+	for _, hash := range looseHashes {
+		if strings.HasPrefix(hash, prefix) {
+			found[hash] = true
+		}
+	}
+
+	return sortedNames(found), nil
+}
+
+/**
+ * Indique si le hash d'un objet correspond à son contenu tel qu'il est stocké
+ * (en-tête compris pour le format typé, contenu seul pour l'ancien format)
+ */
+func HashMatchesContent(hash string) (bool, error) {
+	data, err := defaultStore.readRaw(hash)
+	if err != nil {
+		return false, err
+	}
+	return LegacyBlobHash(data) == hash, nil
+}
+
+/**
+ * Liste les hashes des objets du dépôt courant qui commencent par un préfixe
+ */
+func HashesWithPrefix(prefix string) ([]string, error) {
+	return defaultStore.HashesWithPrefix(prefix)
+}
+
+/**
+ * Résultat d'un repack
+ */
+type RepackStats struct {
+	Objects int
+	Deltas  int
+	Pack    string
+}
+
+/**
+ * Regroupe tous les objets (individuels et déjà packés) dans un nouveau packfile
+ * puis supprime les objets individuels et les anciens packfiles
+ * nameHints associe un hash de blob à un chemin pour rapprocher les versions d'un même fichier
+ */
+func (s *PackedStore) Repack(nameHints map[string]string) (*RepackStats, error) {
+	if err := s.loadPacks(); err != nil {
+		return nil, fmt.Errorf("cannot load packfiles: %v", err)
+	}
+	// Les anciens packs sont supprimés après le repack : un pack corrompu ne doit pas être recopié
+	for _, pack := range s.packs {
+		if err := pack.verify(); err != nil {
+			return nil, err
+		}
+	}
+
+	looseHashes, err := s.loose.list()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list objects: %v", err)
+	}
+	hashes := append([]string{}, looseHashes...)
+	for _, pack := range s.packs {
+		hashes = append(hashes, pack.hashes...)
+	}
+
+	raws := make(map[string][]byte, len(hashes))
+	for _, hash := range hashes {
+		if _, done := raws[hash]; done {
+			continue
+		}
+		data, err := s.readRaw(hash)
+		if err != nil {
+			return nil, err
+		}
+		raws[hash] = data
+	}
+
+	stats := &RepackStats{Objects: len(raws)}
+	if len(raws) == 0 {
+		return stats, nil
+	}
+
+	entries := deltifyObjects(raws, nameHints)
+	for _, entry := range entries {
+		if entry.delta != nil {
+			stats.Deltas++
+		}
+	}
+
+	packPath, err := writePack(s.packDir(), entries)
+	if err != nil {
+		return nil, err
+	}
+	stats.Pack = filepath.Base(packPath)
+
+	// Le nouveau pack est complet : les anciens packs et les objets individuels sont redondants
+	oldPacks := s.packs
+	s.closePacks()
+	for _, pack := range oldPacks {
+		if pack.path != packPath {
+			os.Remove(strings.TrimSuffix(pack.path, ".pack") + ".idx")
+			os.Remove(pack.path)
+		}
+	}
+	for _, hash := range looseHashes {
+		s.loose.remove(hash)
+	}
+
+	return stats, nil
+}
+
+/**
+ * Regroupe les objets du dépôt courant dans un packfile
+ */
+func Repack(nameHints map[string]string) (*RepackStats, error) {
+	return defaultStore.Repack(nameHints)
+}
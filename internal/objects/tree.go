@@ -0,0 +1,282 @@
+package objects
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/**
+ * Modes des entrées d'un tree (mêmes valeurs que Git)
+ */
+const (
+	ModeFile       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeDir        = "040000"
+)
+
+/**
+ * Une entrée d'un objet tree : fichier (blob) ou sous-répertoire (tree)
+ */
+type TreeEntry struct {
+	Mode string
+	Type string
+	Hash string
+	Name string
+}
+
+/**
+ * Un fichier suivi : son mode et le hash de son contenu
+ */
+type FileEntry struct {
+	Mode string
+	Hash string
+}
+
+/**
+ * Calcule le mode d'un fichier du répertoire de travail
+ */
+func FileMode(info os.FileInfo) string {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return ModeSymlink
+	case info.Mode()&0111 != 0:
+		return ModeExecutable
+	default:
+		return ModeFile
+	}
+}
+
+/**
+ * Lit un fichier du répertoire de travail et calcule son entrée (mode + hash)
+ * Pour un lien symbolique, le contenu est la cible du lien
+ */
+func ReadWorkingFile(path string) (FileEntry, []byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return FileEntry{}, nil, err
+	}
+
+	var content []byte
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return FileEntry{}, nil, err
+		}
+		content = []byte(target)
+	} else {
+		if content, err = os.ReadFile(path); err != nil {
+			return FileEntry{}, nil, err
+		}
+	}
+
+	return FileEntry{Mode: FileMode(info), Hash: HashBlob(content)}, content, nil
+}
+
+/**
+ * Écrit un fichier suivi dans le répertoire de travail
+ * Restaure le bit exécutable et les liens symboliques selon le mode
+ */
+func WriteWorkingFile(path string, entry FileEntry) error {
+	content, err := ReadBlob(entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	// Supprimer l'ancienne version (un lien symbolique ne peut pas être réécrit)
+	if _, err := os.Lstat(path); err == nil {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	switch entry.Mode {
+	case ModeSymlink:
+		return os.Symlink(string(content), path)
+	case ModeExecutable:
+		return os.WriteFile(path, content, 0755)
+	default:
+		return os.WriteFile(path, content, 0644)
+	}
+}
+
+/**
+ * Écrit les objets tree d'un ensemble de fichiers (chemin -> entrée)
+ * Un objet tree est créé par répertoire, les sous-répertoires inchangés
+ * conservent donc le même hash d'un commit à l'autre.
+ * Retourne le hash du tree racine.
+ */
+func WriteTree(files map[string]FileEntry) (string, error) {
+	return buildTree(files, Store().Write)
+}
+
+/**
+ * Construit les trees d'un ensemble de fichiers, chaque tree étant confié à store
+ * (écriture dans le dépôt, ou conservation en mémoire pour les anciens trees plats)
+ */
+func buildTree(files map[string]FileEntry, store func(obj Object) (string, error)) (string, error) {
+	entries := make(map[string]TreeEntry)
+	subdirs := make(map[string]map[string]FileEntry)
+
+	for path, file := range files {
+		dir, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			mode := file.Mode
+			if mode == "" {
+				mode = ModeFile
+			}
+			entries[path] = TreeEntry{Mode: mode, Type: TypeBlob, Hash: file.Hash, Name: path}
+			continue
+		}
+		if subdirs[dir] == nil {
+			subdirs[dir] = make(map[string]FileEntry)
+		}
+		subdirs[dir][rest] = file
+	}
+
+	for dir, subFiles := range subdirs {
+		subTree, err := buildTree(subFiles, store)
+		if err != nil {
+			return "", err
+		}
+		entries[dir] = TreeEntry{Mode: ModeDir, Type: TypeTree, Hash: subTree, Name: dir}
+	}
+
+	tree := &Tree{}
+	for _, entry := range entries {
+		tree.Entries = append(tree.Entries, entry)
+	}
+	sortTreeEntries(tree.Entries)
+	return store(tree)
+}
+
+/**
+ * Trees hiérarchiques calculés à partir des anciens trees plats, gardés en mémoire
+ * (hash -> tree) : leur lecture n'écrit rien dans le dépôt, goit migrate objects les enregistre
+ */
+var convertedTrees = make(map[string]*Tree)
+
+func keepConvertedTree(obj Object) (string, error) {
+	hash := Hash(obj)
+	convertedTrees[hash] = obj.(*Tree)
+	return hash, nil
+}
+
+/**
+ * Trie les entrées d'un tree dans l'ordre canonique de Git :
+ * ordre des octets du nom, un sous-répertoire étant comparé comme "nom/"
+ * (ex : "a.txt" avant le répertoire "a", lui-même avant "a0")
+ */
+func sortTreeEntries(entries []TreeEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return treeEntrySortKey(entries[i]) < treeEntrySortKey(entries[j])
+	})
+}
+
+func treeEntrySortKey(entry TreeEntry) string {
+	if entry.Type == TypeTree {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
+
+/**
+ * Lit les entrées d'un objet tree (un seul niveau)
+ * Les anciens trees plats ("hash chemin") sont convertis en mémoire en trees hiérarchiques
+ */
+func ReadTree(treeHash string) ([]TreeEntry, error) {
+	if treeHash == "" {
+		return nil, nil
+	}
+	if tree, ok := convertedTrees[treeHash]; ok {
+		return tree.Entries, nil
+	}
+
+	obj, err := Store().Read(treeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o := obj.(type) {
+	case *Tree:
+		return o.Entries, nil
+	case *Blob:
+		entries, flatFiles, err := parseLegacyTree(o.Data)
+		if err != nil {
+			return nil, fmt.Errorf("object %s: %v", treeHash, err)
+		}
+		if len(flatFiles) > 0 {
+			upgraded, err := buildTree(flatFiles, keepConvertedTree)
+			if err != nil {
+				return nil, err
+			}
+			return convertedTrees[upgraded].Entries, nil
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("object %s is a %s, not a tree", treeHash, obj.Type())
+}
+
+/**
+ * Récupère tous les fichiers d'un tree et de ses sous-trees (chemin -> entrée)
+ */
+func ReadTreeFiles(treeHash string) (map[string]FileEntry, error) {
+	files := make(map[string]FileEntry)
+	if err := collectTreeFiles(treeHash, "", files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func collectTreeFiles(treeHash, prefix string, files map[string]FileEntry) error {
+	entries, err := ReadTree(treeHash)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := prefix + entry.Name
+		if entry.Type == TypeTree {
+			if err := collectTreeFiles(entry.Hash, path+"/", files); err != nil {
+				return err
+			}
+		} else {
+			files[path] = FileEntry{Mode: entry.Mode, Hash: entry.Hash}
+		}
+	}
+	return nil
+}
+
+/**
+ * Récupère le hash du tree d'un commit
+ */
+func GetCommitTree(commitHash string) (string, error) {
+	commit, err := ReadCommit(commitHash)
+	if err != nil {
+		return "", err
+	}
+	if commit.Tree == "" {
+		return "", fmt.Errorf("no tree found in commit %s", commitHash)
+	}
+	return commit.Tree, nil
+}
+
+/**
+ * Récupère tous les fichiers de l'instantané d'un commit (chemin -> entrée)
+ */
+func ReadCommitFiles(commitHash string) (map[string]FileEntry, error) {
+	treeHash, err := GetCommitTree(commitHash)
+	if err != nil {
+		return nil, err
+	}
+	return ReadTreeFiles(treeHash)
+}
@@ -0,0 +1,77 @@
+package repack
+
+import (
+	"fmt"
+	"projet-go-git/internal/index"
+	"projet-go-git/internal/objects"
+	"projet-go-git/internal/repository"
+)
+
+/**
+ * Associe chaque blob connu à un chemin de fichier
+ * Parcourt l'historique accessible depuis les références ainsi que l'index,
+ * pour que le repack rapproche les versions successives d'un même fichier
+ */
+func collectNameHints() (map[string]string, error) {
+	hints := make(map[string]string)
+	addFiles := func(files map[string]objects.FileEntry) {
+		for path, entry := range files {
+			if _, exists := hints[entry.Hash]; !exists {
+				hints[entry.Hash] = path
+			}
+		}
+	}
+
+	visited := make(map[string]bool)
+	for _, ref := range repository.RefCommits() {
+		if ref == "" {
+			continue
+		}
+		ancestors, err := repository.GetAncestors(ref)
+		if err != nil {
+			return nil, err
+		}
+		for commitHash := range ancestors {
+			if visited[commitHash] {
+				continue
+			}
+			visited[commitHash] = true
+
+			files, err := objects.ReadCommitFiles(commitHash)
+			if err != nil {
+				return nil, err
+			}
+			addFiles(files)
+		}
+	}
+
+	files, err := index.ReadFiles()
+	if err != nil {
+		return nil, err
+	}
+	addFiles(files)
+
+	return hints, nil
+}
+
+/**
+ * Regroupe les objets du dépôt dans un packfile avec compression par delta
+ */
+func Repack() error {
+	hints, err := collectNameHints()
+	if err != nil {
+		return err
+	}
+
+	stats, err := objects.Repack(hints)
+	if err != nil {
+		return err
+	}
+	if stats.Objects == 0 {
+		fmt.Println("Nothing to repack")
+		return nil
+	}
+
+	fmt.Printf("Packed %d object(s) (%d delta(s)) into %s\n", stats.Objects, stats.Deltas, stats.Pack)
+	return nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"fmt"
+	"projet-go-git/internal/objects"
+)
+
+/**
+ * Récupère la liste des parents d'un commit dans l'ordre où ils sont enregistrés
+ */
+func GetCommitParents(commitHash string) ([]string, error) {
+	commit, err := objects.ReadCommit(commitHash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Parents, nil
+}
+
+/**
+ * Parcours en largeur de tous les ancêtres d'un commit (lui compris)
+ * Suit tous les parents, y compris le second parent des commits de merge
+ */
+func GetAncestors(commitHash string) (map[string]bool, error) {
+	ancestors := make(map[string]bool)
+	queue := []string{commitHash}
+
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if ancestors[hash] {
+			continue
+		}
+		ancestors[hash] = true
+
+		parents, err := GetCommitParents(hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, parents...)
+	}
+
+	return ancestors, nil
+}
+
+/**
+ * Vérifie si ancestor est un ancêtre de (ou égal à) commitHash
+ */
+func IsAncestor(ancestor, commitHash string) (bool, error) {
+	ancestors, err := GetAncestors(commitHash)
+	if err != nil {
+		return false, err
+	}
+	return ancestors[ancestor], nil
+}
+
+/**
+ * Calcule les meilleurs ancêtres communs de deux commits (lowest common ancestors)
+ * Un ancêtre commun est retenu s'il n'est l'ancêtre d'aucun autre ancêtre commun
+ * Plusieurs bases sont retournées en cas de merges croisés (criss-cross)
+ */
+func MergeBases(commit1, commit2 string) ([]string, error) {
+	ancestors1, err := GetAncestors(commit1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parcours depuis commit2 en s'arrêtant sur les ancêtres communs rencontrés
+	var candidates []string
+	visited := make(map[string]bool)
+	queue := []string{commit2}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if visited[hash] {
+			continue
+		}
+		visited[hash] = true
+
+		if ancestors1[hash] {
+			candidates = append(candidates, hash)
+			continue
+		}
+
+		parents, err := GetCommitParents(hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, parents...)
+	}
+
+	if len(candidates) <= 1 {
+		return candidates, nil
+	}
+
+	// Éliminer les candidats atteignables depuis un autre candidat
+	redundant := make(map[string]bool)
+	queue = nil
+	for _, candidate := range candidates {
+		parents, err := GetCommitParents(candidate)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, parents...)
+	}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if redundant[hash] {
+			continue
+		}
+		redundant[hash] = true
+
+		parents, err := GetCommitParents(hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, parents...)
+	}
+
+	var bases []string
+	for _, candidate := range candidates {
+		if !redundant[candidate] {
+			bases = append(bases, candidate)
+		}
+	}
+	return bases, nil
+}
+
+/**
+ * Affiche la base de merge de deux révisions (commande merge-base)
+ * Avec all, affiche toutes les bases au lieu de la première seulement
+ */
+func ShowMergeBase(rev1, rev2 string, all bool) error {
+	commit1, err := ResolveRevision(rev1)
+	if err != nil {
+		return err
+	}
+	commit2, err := ResolveRevision(rev2)
+	if err != nil {
+		return err
+	}
+
+	bases, err := MergeBases(commit1, commit2)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 0 {
+		return fmt.Errorf("no common ancestor found")
+	}
+
+	if !all {
+		bases = bases[:1]
+	}
+	for _, base := range bases {
+		fmt.Println(base)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hash enregistré dans le reflog quand une référence n'existait pas encore
+const zeroHash = "0000000000000000000000000000000000000000"
+
+/**
+ * Une entrée du reflog : ancienne et nouvelle valeur d'une référence
+ */
+type ReflogEntry struct {
+	OldHash string
+	NewHash string
+	When    time.Time
+	Reason  string
+}
+
+func reflogPath(ref string) string {
+	return filepath.Join(".goit", "logs", filepath.FromSlash(ref))
+}
+
+/**
+ * Vérifie qu'un nom de branche ou de tag pourra être relu par ResolveRevision
+ * Sont refusés les caractères de la syntaxe des révisions (.., ~, ^, :, @{), un "-" initial
+ * (pris pour une option), "/" et les espaces, ainsi que les références symboliques réservées
+ */
+func CheckRefName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("empty name")
+	case name == "HEAD" || name == "@" || name == "ORIG_HEAD" || name == "MERGE_HEAD":
+		return fmt.Errorf("'%s' is a reserved name", name)
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("a name cannot start with '-'")
+	}
+	for _, forbidden := range []string{"..", "~", "^", ":", "@{", "/", " "} {
+		if strings.Contains(name, forbidden) {
+			return fmt.Errorf("a name cannot contain '%s'", forbidden)
+		}
+	}
+	return nil
+}
+
+/**
+ * Lit le hash pointé par une référence (ex : refs/heads/main)
+ */
+func ReadRef(ref string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(".goit", filepath.FromSlash(ref)))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+/**
+ * Met à jour une référence (ex : refs/heads/main, ou HEAD s'il est détaché)
+ * et enregistre le changement dans son reflog
+ */
+func UpdateRef(ref, commitHash, reason string) error {
+	oldHash, _ := ReadRef(ref)
+
+	refFile := filepath.Join(".goit", filepath.FromSlash(ref))
+	if err := os.MkdirAll(filepath.Dir(refFile), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(refFile, []byte(commitHash), 0644); err != nil {
+		return fmt.Errorf("failed to update %s: %v", ref, err)
+	}
+	return AppendReflog(ref, oldHash, commitHash, reason)
+}
+
+/**
+ * Enregistre la position de HEAD avant une opération qui la déplace (reset, merge)
+ * pour pouvoir y revenir avec ORIG_HEAD
+ */
+func WriteOrigHead(commitHash string) error {
+	if commitHash == "" {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(".goit", "ORIG_HEAD"), []byte(commitHash), 0644); err != nil {
+		return fmt.Errorf("failed to write ORIG_HEAD: %v", err)
+	}
+	return nil
+}
+
+/**
+ * Ajoute une entrée au reflog d'une référence (.goit/logs/<ref>)
+ * Format : "<ancien hash> <nouveau hash> <timestamp unix> <raison>"
+ */
+func AppendReflog(ref, oldHash, newHash, reason string) error {
+	if oldHash == "" {
+		oldHash = zeroHash
+	}
+
+	path := reflogPath(ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write reflog for %s: %v", ref, err)
+	}
+	defer f.Close()
+
+	reason = strings.ReplaceAll(reason, "\n", " ")
+	_, err = fmt.Fprintf(f, "%s %s %d %s\n", oldHash, newHash, time.Now().Unix(), reason)
+	return err
+}
+
+/**
+ * Lit le reflog d'une référence, de la plus ancienne à la plus récente entrée
+ */
+func ReadReflog(ref string) ([]ReflogEntry, error) {
+	data, err := os.ReadFile(reflogPath(ref))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []ReflogEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) < 3 {
+			continue
+		}
+		entry := ReflogEntry{OldHash: fields[0], NewHash: fields[1]}
+		if seconds, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			entry.When = time.Unix(seconds, 0)
+		}
+		if len(fields) == 4 {
+			entry.Reason = fields[3]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"projet-go-git/internal/config"
+	"projet-go-git/internal/index"
 	"projet-go-git/internal/objects"
 	"strings"
 )
 
+// Clé de configuration indiquant que les commits du dépôt sont des instantanés complets
+// (dépôt créé par cette version ou déjà passé par goit migrate snapshots)
+const FullSnapshotsKey = "core.fullSnapshots"
+
 /**
  * Vérifie si le répertoire actuel est un repository goit
  */
@@ -31,6 +37,7 @@ func Init() {
 		".goit/objects",
 		".goit/refs",
 		".goit/refs/heads",
+		".goit/refs/tags",
 	}
 
 	for _, dir := range dirs {
@@ -53,55 +60,91 @@ func Init() {
 		return
 	}
 
+	// Les commits de ce dépôt seront des instantanés complets : rien à migrer
+	if err := config.Set(FullSnapshotsKey, "true", false); err != nil {
+		fmt.Printf("Failed to create config: %v\n", err)
+		return
+	}
+
 	fmt.Println("Initialized empty goit repository")
 }
 
+/**
+ * Vérifie que les commits du dépôt sont des instantanés complets avant d'en créer un
+ * Dans un dépôt créé par une ancienne version, les commits et l'index ne contiennent que les
+ * fichiers stagés : un nouveau commit supprimerait tous les autres fichiers
+ */
+func RequireFullSnapshots() error {
+	if full, _ := config.Get(FullSnapshotsKey); full != "true" {
+		return fmt.Errorf("the commits of this repository only contain the files staged by an older goit version\nrun 'goit migrate snapshots' first to rebuild them as full snapshots")
+	}
+	return nil
+}
+
 /**
  * Crée un nouveau commit avec les fichiers de l'index
+ * L'index contient l'instantané complet des fichiers suivis et est conservé après le commit
  * Gère les commits parents et met à jour les références
  */
 func Commit(message string) {
-	indexPath := filepath.Join(".goit", "index")
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		fmt.Println("Nothing to commit (create/copy files and use \"goit add\" to track)")
+	if err := RequireFullSnapshots(); err != nil {
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	treeHash := objects.CreateTree()
+	if err := index.EnsureIndex(); err != nil {
+		fmt.Printf("Failed to prepare index: %v\n", err)
+		return
+	}
 
-	// Récupérer le hash du commit parent (HEAD actuel)
-	var parentHash string
-	headContent, err := GetHEAD()
-	if err == nil && strings.HasPrefix(headContent, "ref: ") {
-		refPath := strings.TrimPrefix(headContent, "ref: ")
-		refFile := filepath.Join(".goit", refPath)
-		if content, err := os.ReadFile(refFile); err == nil {
-			parentHash = strings.TrimSpace(string(content))
+	if unmerged, err := index.UnmergedPaths(); err == nil && len(unmerged) > 0 {
+		fmt.Println("Committing is not possible because you have unmerged files:")
+		for _, path := range unmerged {
+			fmt.Printf("\t%s\n", path)
 		}
+		fmt.Println("Fix them, mark them with \"goit add <file>\" and finish the merge with \"goit resolve\"")
+		return
 	}
 
-	commitHash := objects.CreateCommit(treeHash, message, parentHash)
+	hasChanges, err := index.HasStagedChanges()
+	if err != nil {
+		fmt.Printf("Failed to read index: %v\n", err)
+		return
+	}
+	if !hasChanges {
+		fmt.Println("Nothing to commit (create/copy files and use \"goit add\" to track)")
+		return
+	}
 
-	// Résoudre la référence HEAD actuelle et mettre à jour
-	headContent, err = GetHEAD()
-	if err == nil && strings.HasPrefix(headContent, "ref: ") {
-		refPath := strings.TrimPrefix(headContent, "ref: ")
-		refFile := filepath.Join(".goit", refPath)
-		if err := os.WriteFile(refFile, []byte(commitHash), 0644); err != nil {
-			fmt.Printf("Failed to update branch reference: %v\n", err)
-			return
-		}
-	} else {
-		// Si HEAD contient directement un hash, utiliser main par défaut
-		refFile := filepath.Join(".goit", "refs", "heads", "main")
-		if err := os.WriteFile(refFile, []byte(commitHash), 0644); err != nil {
-			fmt.Printf("Failed to update main reference: %v\n", err)
-			return
-		}
+	files, err := index.ReadFiles()
+	if err != nil {
+		fmt.Printf("Failed to read index: %v\n", err)
+		return
+	}
+	treeHash, err := objects.WriteTree(files)
+	if err != nil {
+		fmt.Printf("Failed to write tree: %v\n", err)
+		return
 	}
 
-	// Vider l'index après le commit
-	os.Remove(indexPath)
+	// Le commit parent est le commit actuel, que HEAD pointe sur une branche ou soit détaché
+	parentHash, err := GetCurrentCommitHash()
+	if err != nil {
+		fmt.Printf("Failed to read HEAD: %v\n", err)
+		return
+	}
+
+	commitHash, err := objects.CreateCommit(treeHash, message, []string{parentHash})
+	if err != nil {
+		fmt.Printf("Failed to write commit: %v\n", err)
+		return
+	}
+
+	title, _, _ := strings.Cut(message, "\n")
+	if err := UpdateHEAD(commitHash, "commit: "+title); err != nil {
+		fmt.Printf("Failed to update HEAD: %v\n", err)
+		return
+	}
 
 	fmt.Printf("Committed: %s\n", commitHash[:8])
 }
@@ -142,6 +185,36 @@ func GetHEAD() (string, error) {
 	return strings.TrimSpace(string(data)), nil
 }
 
+/**
+ * Fait avancer HEAD vers un nouveau commit
+ * Met à jour la branche courante, ou HEAD lui-même s'il est détaché
+ * reason est enregistrée dans le reflog (ex : "commit: message")
+ */
+func UpdateHEAD(commitHash, reason string) error {
+	head, err := GetHEAD()
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(head, "ref: ") {
+		return UpdateRef("HEAD", commitHash, reason)
+	}
+
+	oldHash, _ := GetCurrentCommitHash()
+	if err := UpdateRef(strings.TrimPrefix(head, "ref: "), commitHash, reason); err != nil {
+		return err
+	}
+	return AppendReflog("HEAD", oldHash, commitHash, reason)
+}
+
+/**
+ * Indique si HEAD est détaché (pointe directement sur un commit)
+ */
+func IsDetached() bool {
+	head, err := GetHEAD()
+	return err == nil && head != "" && !strings.HasPrefix(head, "ref: ")
+}
+
 /**
  * Récupère le hash du commit actuel
  * Résout HEAD vers le hash du commit si nécessaire
@@ -165,3 +238,62 @@ func GetCurrentCommitHash() (string, error) {
 	// HEAD pointe directement sur un commit (HEAD détaché)
 	return head, nil
 }
+
+/**
+ * Récupère les fichiers (chemin -> entrée) de l'arbre d'un commit
+ * Un hash vide (aucun commit) correspond à un arbre vide
+ */
+func CommitFiles(commitHash string) (map[string]objects.FileEntry, error) {
+	if commitHash == "" {
+		return make(map[string]objects.FileEntry), nil
+	}
+	files, err := objects.ReadCommitFiles(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tree of commit %s: %v", commitHash, err)
+	}
+	return files, nil
+}
+
+/**
+ * Récupère les fichiers de l'arbre du commit HEAD (vide s'il n'y a pas encore de commit)
+ */
+func HeadFiles() (map[string]objects.FileEntry, error) {
+	commitHash, err := GetCurrentCommitHash()
+	if err != nil {
+		return nil, err
+	}
+	return CommitFiles(commitHash)
+}
+
+/**
+ * Liste les commits pointés par une référence (branches, tags, HEAD détaché, MERGE_HEAD)
+ */
+func RefCommits() []string {
+	var commits []string
+
+	for _, refDir := range []string{"heads", "tags"} {
+		dir := filepath.Join(".goit", "refs", refDir)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if data, err := os.ReadFile(filepath.Join(dir, entry.Name())); err == nil {
+				commits = append(commits, strings.TrimSpace(string(data)))
+			}
+		}
+	}
+
+	if head, err := GetHEAD(); err == nil && head != "" && !strings.HasPrefix(head, "ref: ") {
+		commits = append(commits, head)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(".goit", "MERGE_HEAD")); err == nil {
+		commits = append(commits, strings.TrimSpace(string(data)))
+	}
+
+	return commits
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"projet-go-git/internal/objects"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Référence suivie de "@{n}" : n-ième valeur précédente dans le reflog
+var reflogSuffix = regexp.MustCompile(`^(.*)@\{(\d+)\}$`)
+
+/**
+ * Résout une expression de révision vers le hash d'un commit
+ * Accepte HEAD (ou @), ORIG_HEAD, MERGE_HEAD, un nom de branche, un tag, un hash complet ou un préfixe de hash unique,
+ * suivis éventuellement de :
+ * - @{n} : n-ième valeur précédente de la référence (reflog), ex : main@{2}
+ * - ~n : n-ième ancêtre en suivant le premier parent, ex : HEAD~3
+ * - ^n : n-ième parent, ex : main^2 pour le second parent d'un merge
+ */
+func ResolveRevision(rev string) (string, error) {
+	if strings.Contains(rev, "..") {
+		return "", fmt.Errorf("revision range %s is not allowed here", rev)
+	}
+
+	base, suffix := rev, ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base, suffix = rev[:i], rev[i:]
+	}
+
+	hash, err := resolveBase(base)
+	if err != nil {
+		return "", err
+	}
+
+	for suffix != "" {
+		// Chaque suffixe est "~" ou "^" suivi d'un nombre facultatif ; tout autre caractère
+		// (y compris après le nombre, comme dans HEAD~2x) rend la révision invalide
+		op := suffix[0]
+		if op != '~' && op != '^' {
+			return "", fmt.Errorf("invalid revision %s", rev)
+		}
+		digits := 0
+		for digits+1 < len(suffix) && suffix[digits+1] >= '0' && suffix[digits+1] <= '9' {
+			digits++
+		}
+		n := 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(suffix[1 : digits+1]); err != nil {
+				return "", fmt.Errorf("invalid revision %s", rev)
+			}
+		}
+		suffix = suffix[digits+1:]
+
+		if op == '~' {
+			for i := 0; i < n; i++ {
+				if hash, err = nthParent(hash, 1, rev); err != nil {
+					return "", err
+				}
+			}
+		} else if n > 0 {
+			if hash, err = nthParent(hash, n, rev); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return hash, nil
+}
+
+/**
+ * Retourne le n-ième parent d'un commit (à partir de 1)
+ */
+func nthParent(commitHash string, n int, rev string) (string, error) {
+	parents, err := GetCommitParents(commitHash)
+	if err != nil {
+		return "", err
+	}
+	if n > len(parents) {
+		return "", fmt.Errorf("invalid revision %s: commit %s has no parent %d", rev, commitHash[:8], n)
+	}
+	return parents[n-1], nil
+}
+
+/**
+ * Résout le début d'une expression (avant ~ et ^), avec un éventuel suffixe @{n}
+ */
+func resolveBase(name string) (string, error) {
+	if m := reflogSuffix.FindStringSubmatch(name); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		return resolveReflog(m[1], n)
+	}
+	if name == "@" {
+		name = "HEAD"
+	}
+	return resolveName(name)
+}
+
+/**
+ * Résout HEAD, ORIG_HEAD, MERGE_HEAD, une branche, un tag, un hash complet ou un préfixe de hash unique
+ */
+func resolveName(name string) (string, error) {
+	if name == "HEAD" {
+		hash, err := GetCurrentCommitHash()
+		if err != nil || hash == "" {
+			return "", fmt.Errorf("HEAD does not point to a commit")
+		}
+		return hash, nil
+	}
+
+	if name == "ORIG_HEAD" || name == "MERGE_HEAD" {
+		hash, err := ReadRef(name)
+		if err != nil || hash == "" {
+			return "", fmt.Errorf("%s is not set", name)
+		}
+		return hash, nil
+	}
+
+	for _, refDir := range []string{"heads", "tags"} {
+		refFile := filepath.Join(".goit", "refs", refDir, name)
+		if data, err := os.ReadFile(refFile); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+
+	if len(name) == 40 && objects.Store().Has(name) {
+		return name, nil
+	}
+
+	if len(name) >= 4 && len(name) < 40 {
+		return resolveShortHash(name)
+	}
+
+	return "", fmt.Errorf("unknown revision: %s", name)
+}
+
+/**
+ * Résout un préfixe de hash vers l'unique commit qui commence par ce préfixe
+ */
+func resolveShortHash(prefix string) (string, error) {
+	hashes, err := objects.HashesWithPrefix(strings.ToLower(prefix))
+	if err != nil {
+		return "", err
+	}
+
+	var commits []string
+	for _, hash := range hashes {
+		if _, err := objects.ReadCommit(hash); err == nil {
+			commits = append(commits, hash)
+		}
+	}
+
+	switch len(commits) {
+	case 0:
+		return "", fmt.Errorf("unknown revision: %s", prefix)
+	case 1:
+		return commits[0], nil
+	}
+	return "", fmt.Errorf("short hash %s is ambiguous (%d commits match)", prefix, len(commits))
+}
+
+/**
+ * Résout ref@{n} grâce au reflog de la référence (HEAD si ref est vide)
+ */
+func resolveReflog(name string, n int) (string, error) {
+	ref := "HEAD"
+	if name != "" && name != "HEAD" && name != "@" {
+		ref = "refs/heads/" + name
+		if _, err := ReadRef(ref); err != nil {
+			return "", fmt.Errorf("unknown branch: %s", name)
+		}
+	}
+	if n == 0 {
+		return resolveBase(strings.TrimPrefix(ref, "refs/heads/"))
+	}
+
+	entries, err := ReadReflog(ref)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("log for '%s' only has %d entries", strings.TrimPrefix(ref, "refs/heads/"), len(entries))
+	}
+	return entries[len(entries)-1-n].NewHash, nil
+}
+
+/**
+ * Interprète une plage de révisions
+ * - A..B : commits accessibles depuis B mais pas depuis A
+ * - A...B : commits accessibles depuis A ou B mais pas depuis les deux
+ * - A : commits accessibles depuis A
+ * Un côté vide vaut HEAD. Retourne les commits de départ et les commits exclus (avec leurs ancêtres).
+ */
+func ParseRange(expr string) ([]string, []string, error) {
+	if left, right, symmetric := strings.Cut(expr, "..."); symmetric {
+		a, err := ResolveRevision(defaultHEAD(left))
+		if err != nil {
+			return nil, nil, err
+		}
+		b, err := ResolveRevision(defaultHEAD(right))
+		if err != nil {
+			return nil, nil, err
+		}
+		bases, err := MergeBases(a, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []string{a, b}, bases, nil
+	}
+
+	if left, right, isRange := strings.Cut(expr, ".."); isRange {
+		a, err := ResolveRevision(defaultHEAD(left))
+		if err != nil {
+			return nil, nil, err
+		}
+		b, err := ResolveRevision(defaultHEAD(right))
+		if err != nil {
+			return nil, nil, err
+		}
+		return []string{b}, []string{a}, nil
+	}
+
+	hash, err := ResolveRevision(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{hash}, nil, nil
+}
+
+/**
+ * Interprète les deux commits comparés par un diff
+ * - A..B : A et B
+ * - A...B : la base de fusion de A et B, et B (modifications de B depuis qu'il a divergé de A)
+ * isRange vaut false si expr n'est pas une plage
+ */
+func ParseDiffRange(expr string) (string, string, bool, error) {
+	left, right, symmetric := strings.Cut(expr, "...")
+	if !symmetric {
+		var isRange bool
+		if left, right, isRange = strings.Cut(expr, ".."); !isRange {
+			return "", "", false, nil
+		}
+	}
+
+	a, err := ResolveRevision(defaultHEAD(left))
+	if err != nil {
+		return "", "", true, err
+	}
+	b, err := ResolveRevision(defaultHEAD(right))
+	if err != nil {
+		return "", "", true, err
+	}
+	if symmetric {
+		bases, err := MergeBases(a, b)
+		if err != nil {
+			return "", "", true, err
+		}
+		if len(bases) == 0 {
+			return "", "", true, fmt.Errorf("%s: no merge base", expr)
+		}
+		a = bases[0]
+	}
+	return a, b, true, nil
+}
+
+func defaultHEAD(rev string) string {
+	if rev == "" {
+		return "HEAD"
+	}
+	return rev
+}
+
+/**
+ * Liste les commits accessibles depuis include (tous les parents suivis)
+ * qui ne sont pas accessibles depuis exclude, du plus récent au plus ancien
+ */
+func RevList(include, exclude []string) ([]string, error) {
+	excluded := make(map[string]bool)
+	for _, hash := range exclude {
+		ancestors, err := GetAncestors(hash)
+		if err != nil {
+			return nil, err
+		}
+		for ancestor := range ancestors {
+			excluded[ancestor] = true
+		}
+	}
+
+	type listed struct {
+		hash  string
+		order int
+		time  int64
+	}
+	var commits []listed
+	visited := make(map[string]bool)
+	queue := append([]string{}, include...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if visited[hash] || excluded[hash] {
+			continue
+		}
+		visited[hash] = true
+
+		commit, err := objects.ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		entry := listed{hash: hash, order: len(commits)}
+		if t, ok := commit.Time(); ok {
+			entry.time = t.Unix()
+		}
+		commits = append(commits, entry)
+		queue = append(queue, commit.Parents...)
+	}
+
+	// Du plus récent au plus ancien, l'ordre de parcours départage les commits de la même seconde
+	sort.SliceStable(commits, func(i, j int) bool {
+		if commits[i].time != commits[j].time {
+			return commits[i].time > commits[j].time
+		}
+		return commits[i].order < commits[j].order
+	})
+
+	hashes := make([]string, len(commits))
+	for i, commit := range commits {
+		hashes[i] = commit.hash
+	}
+	return hashes, nil
+}
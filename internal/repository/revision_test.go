@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"projet-go-git/internal/objects"
+	"testing"
+)
+
+func TestResolveRevision(t *testing.T) {
+	t.Chdir(t.TempDir())
+	Init()
+
+	// main : first <- second <- merge, merge a pour second parent side (issu de first)
+	tree, _ := objects.WriteTree(map[string]objects.FileEntry{})
+	commit := func(message string, parents ...string) string {
+		hash, err := objects.CreateCommit(tree, message, parents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	first := commit("first")
+	second := commit("second", first)
+	side := commit("side", first)
+	merge := commit("merge", second, side)
+	for _, hash := range []string{first, second, merge} {
+		if err := UpdateRef("refs/heads/main", hash, "commit"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	valid := map[string]string{
+		"HEAD":         merge,
+		"HEAD~":        second,
+		"HEAD~2":       first,
+		"HEAD^2":       side,
+		"HEAD^0":       merge,
+		"main^2~1":     first,
+		"main@{1}":     second,
+		second[:8]:     second,
+		"main@{2}^0~0": first,
+	}
+	for rev, want := range valid {
+		if got, err := ResolveRevision(rev); err != nil || got != want {
+			t.Errorf("%s: got %s (%v), want %s", rev, got, err, want)
+		}
+	}
+
+	for _, rev := range []string{"HEAD~1x", "main~1foo", "HEAD^x", "HEAD~99999999999999999999", "HEAD~3", "HEAD^3", "HEAD..main", "unknown"} {
+		if hash, err := ResolveRevision(rev); err == nil {
+			t.Errorf("%s resolved to %s, expected an error", rev, hash)
+		}
+	}
+}
+
+func TestCheckRefName(t *testing.T) {
+	for _, name := range []string{"main", "feature-1", "v1.0", "fix_a.b", "user@host"} {
+		if err := CheckRefName(name); err != nil {
+			t.Errorf("%s rejected: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "HEAD", "@", "ORIG_HEAD", "MERGE_HEAD", "-b", "x~1", "x^2", "a..b", "foo@{1}", "a:b", "a/b", "a b"} {
+		if err := CheckRefName(name); err == nil {
+			t.Errorf("%q accepted", name)
+		}
+	}
+}
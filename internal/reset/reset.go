@@ -0,0 +1,144 @@
+package reset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"projet-go-git/internal/checkout"
+	"projet-go-git/internal/index"
+	"projet-go-git/internal/objects"
+	"projet-go-git/internal/repository"
+	"sort"
+)
+
+/**
+ * Modes de goit reset
+ * - soft : déplace seulement la branche courante
+ * - mixed : déplace la branche et réécrit l'index depuis l'arbre cible
+ * - hard : déplace la branche, réécrit l'index et le répertoire de travail
+ * - merge : comme hard, mais seuls les fichiers touchés (index ou merge en cours) sont réécrits,
+ *   les modifications non indexées des autres fichiers sont conservées
+ */
+const (
+	ModeSoft  = "soft"
+	ModeMixed = "mixed"
+	ModeHard  = "hard"
+	ModeMerge = "merge"
+)
+
+/**
+ * Déplace la branche courante (ou HEAD détaché) vers une révision
+ * L'ancien commit est conservé dans ORIG_HEAD
+ */
+func Reset(mode, rev string) error {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	targetHash, err := repository.ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+
+	currentHash, err := repository.GetCurrentCommitHash()
+	if err != nil {
+		return err
+	}
+
+	if mode == ModeSoft {
+		if _, err := os.Stat(filepath.Join(".goit", "MERGE_HEAD")); err == nil {
+			return fmt.Errorf("cannot do a soft reset in the middle of a merge")
+		}
+	}
+
+	switch mode {
+	case ModeHard:
+		if err := checkout.ResetWorkingTree(currentHash, targetHash); err != nil {
+			return err
+		}
+	case ModeMerge:
+		mergeHash, _ := repository.ReadRef("MERGE_HEAD")
+		if err := checkout.ResetMergeWorkingTree(targetHash, mergeHash); err != nil {
+			return err
+		}
+	case ModeMixed:
+		files, err := objects.ReadCommitFiles(targetHash)
+		if err != nil {
+			return err
+		}
+		if err := index.WriteEntries(files); err != nil {
+			return fmt.Errorf("failed to write index: %v", err)
+		}
+	}
+
+	if err := repository.WriteOrigHead(currentHash); err != nil {
+		return err
+	}
+	if err := repository.UpdateHEAD(targetHash, "reset: moving to "+rev); err != nil {
+		return err
+	}
+
+	// Un reset de l'index abandonne le merge en cours
+	if mode != ModeSoft {
+		os.Remove(filepath.Join(".goit", "MERGE_HEAD"))
+	}
+
+	switch mode {
+	case ModeHard:
+		fmt.Printf("HEAD is now at %s %s\n", targetHash[:8], commitTitle(targetHash))
+	case ModeMixed:
+		showUnstaged()
+	}
+	return nil
+}
+
+/**
+ * Retire des fichiers de l'index en reprenant leur version d'une révision (HEAD par défaut)
+ * Le répertoire de travail n'est pas modifié
+ */
+func ResetPaths(rev string, paths []string) error {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return checkout.Restore(paths, rev, true, false)
+}
+
+/**
+ * Première ligne du message d'un commit
+ */
+func commitTitle(commitHash string) string {
+	commit, err := objects.ReadCommit(commitHash)
+	if err != nil {
+		return ""
+	}
+	return commit.Title()
+}
+
+/**
+ * Liste les fichiers suivis dont le répertoire de travail diffère de l'index après un reset
+ */
+func showUnstaged() {
+	files, err := index.ReadFiles()
+	if err != nil {
+		return
+	}
+
+	var changes []string
+	for path, entry := range files {
+		current, _, err := objects.ReadWorkingFile(path)
+		switch {
+		case err != nil:
+			changes = append(changes, "D\t"+path)
+		case current != entry:
+			changes = append(changes, "M\t"+path)
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i][2:] < changes[j][2:] })
+	fmt.Println("Unstaged changes after reset:")
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+}
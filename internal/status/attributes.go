@@ -0,0 +1,81 @@
+package status
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+)
+
+// Nombre d'octets examinés pour détecter un fichier binaire
+const binaryCheckSize = 8000
+
+/**
+ * Règle du fichier .goitattributes : un motif et le type de diff à utiliser
+ */
+type attributeRule struct {
+	pattern string
+	binary  bool
+}
+
+var attributeRules []attributeRule
+var attributesLoaded bool
+
+/**
+ * Lit les règles de .goitattributes à la racine du dépôt
+ * Format : "<motif> <attribut>..." ; "binary" ou "-diff" marque les fichiers comme binaires,
+ * "diff" ou "text" force le diff texte. La dernière règle qui correspond l'emporte.
+ */
+func loadAttributes() []attributeRule {
+	if attributesLoaded {
+		return attributeRules
+	}
+	attributesLoaded = true
+
+	data, err := os.ReadFile(".goitattributes")
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "binary", "-diff":
+				attributeRules = append(attributeRules, attributeRule{pattern: fields[0], binary: true})
+			case "diff", "text":
+				attributeRules = append(attributeRules, attributeRule{pattern: fields[0], binary: false})
+			}
+		}
+	}
+	return attributeRules
+}
+
+/**
+ * Vérifie si un motif de .goitattributes correspond à un chemin
+ * Un motif sans "/" s'applique au nom du fichier dans n'importe quel répertoire
+ */
+func (r attributeRule) matches(filePath string) bool {
+	if strings.Contains(r.pattern, "/") {
+		matched, _ := path.Match(strings.TrimPrefix(r.pattern, "/"), filePath)
+		return matched
+	}
+	matched, _ := path.Match(r.pattern, path.Base(filePath))
+	return matched
+}
+
+/**
+ * Indique si un fichier doit être traité comme binaire :
+ * d'après .goitattributes, sinon s'il contient un octet nul dans ses premiers octets
+ */
+func isBinary(filePath string, content []byte) bool {
+	rules := loadAttributes()
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(filePath) {
+			return rules[i].binary
+		}
+	}
+	return bytes.IndexByte(content[:min(len(content), binaryCheckSize)], 0) >= 0
+}
@@ -0,0 +1,388 @@
+package status
+
+import (
+	"fmt"
+	"os"
+	"projet-go-git/internal/config"
+	"projet-go-git/internal/diff"
+	"projet-go-git/internal/index"
+	"projet-go-git/internal/objects"
+	"projet-go-git/internal/repository"
+	"sort"
+	"strings"
+)
+
+/**
+ * Options de goit diff
+ * - Staged : compare l'index à HEAD (ou à Revs[0])
+ * - Revs : aucune (index / répertoire de travail), une (commit / répertoire de travail, ou plage A..B, A...B)
+ *   ou deux révisions (arbre / arbre)
+ * - Paths : limite le diff à ces fichiers ou répertoires
+ * - Format : diff unifié si vide, sinon DiffStat, DiffNumstat ou DiffShortstat
+ * - Algorithm : myers, minimal, patience ou histogram (par défaut diff.algorithm de la configuration, sinon myers)
+ * - WordDiff : compare les lignes modifiées mot à mot (WordDiffPlain, WordDiffColor ou WordDiffPorcelain)
+ * - WordRegex : expression qui définit un mot (par défaut diff.wordRegex de la configuration, sinon \S+)
+ */
+type DiffOptions struct {
+	Staged    bool
+	Revs      []string
+	Paths     []string
+	Format    string
+	Algorithm string
+	WordDiff  string
+	WordRegex string
+}
+
+/**
+ * Formats de sortie d'un diff
+ * - DiffPatch : diff unifié
+ * - DiffStat : fichiers modifiés avec leur nombre de lignes changées et un histogramme
+ * - DiffNumstat : lignes ajoutées et supprimées par fichier, pour les scripts
+ * - DiffShortstat : seulement la ligne de résumé
+ */
+const (
+	DiffPatch     = "patch"
+	DiffStat      = "stat"
+	DiffNumstat   = "numstat"
+	DiffShortstat = "shortstat"
+)
+
+/**
+ * Un fichier qui diffère entre les deux côtés comparés
+ */
+type fileChange struct {
+	path   string
+	before *fileVersion
+	after  *fileVersion
+}
+
+/**
+ * Une version d'un fichier à comparer (nil si le fichier n'existe pas de ce côté)
+ * Le contenu d'une version stockée dans les objets n'est lu qu'au besoin
+ */
+type fileVersion struct {
+	entry   objects.FileEntry
+	content []byte
+	loaded  bool
+}
+
+/**
+ * Contenu d'une version, lu depuis les objets au premier appel
+ */
+func (v *fileVersion) read() ([]byte, error) {
+	if !v.loaded {
+		content, err := objects.ReadBlob(v.entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		v.content, v.loaded = content, true
+	}
+	return v.content, nil
+}
+
+/**
+ * Affiche les différences entre deux états du dépôt choisis par opts
+ * Sans chemin, tous les fichiers sont comparés ; un répertoire désigne tous ses fichiers
+ */
+func ShowDiff(opts DiffOptions) error {
+	before, after, err := diffSides(opts)
+	if err != nil {
+		return err
+	}
+	return printChanges(listChanges(before, after, index.CleanPathspecs(opts.Paths)), opts)
+}
+
+/**
+ * Affiche les différences introduites par un commit par rapport à son parent
+ * (un parent vide compare à un arbre vide)
+ * Staged et Revs de opts sont ignorés
+ */
+func ShowCommitDiff(parentHash, commitHash string, opts DiffOptions) error {
+	before, after, err := commitVersions(parentHash, commitHash)
+	if err != nil {
+		return err
+	}
+	return printChanges(listChanges(before, after, index.CleanPathspecs(opts.Paths)), opts)
+}
+
+/**
+ * Algorithme de diff demandé, sinon celui de la configuration (diff.algorithm), sinon Myers
+ */
+func diffAlgorithm(name string) (diff.Algorithm, error) {
+	if name == "" {
+		name, _ = config.Get("diff.algorithm")
+	}
+	if name == "" {
+		return diff.DefaultAlgorithm, nil
+	}
+	return diff.ParseAlgorithm(name)
+}
+
+/**
+ * Liste, triés par chemin, les fichiers qui diffèrent entre deux côtés et correspondent aux chemins demandés
+ */
+func listChanges(before, after map[string]*fileVersion, pathspecs []string) []fileChange {
+	paths := make(map[string]bool)
+	for _, side := range []map[string]*fileVersion{before, after} {
+		for path := range side {
+			if len(pathspecs) == 0 || index.MatchesPathspec(path, pathspecs) {
+				paths[path] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var changes []fileChange
+	for _, path := range sorted {
+		oldVersion, newVersion := before[path], after[path]
+		if oldVersion != nil && newVersion != nil && oldVersion.entry == newVersion.entry {
+			continue
+		}
+		changes = append(changes, fileChange{path: path, before: oldVersion, after: newVersion})
+	}
+	return changes
+}
+
+/**
+ * Affiche une liste de changements dans le format demandé
+ */
+func printChanges(changes []fileChange, opts DiffOptions) error {
+	algorithm, err := diffAlgorithm(opts.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	format := opts.Format
+	if format != DiffPatch && format != "" {
+		stats, err := computeStats(changes, algorithm)
+		if err != nil {
+			return err
+		}
+		switch format {
+		case DiffStat:
+			printStat(stats)
+		case DiffNumstat:
+			printNumstat(stats)
+		case DiffShortstat:
+			printShortstat(stats)
+		default:
+			return fmt.Errorf("unknown diff format %q", format)
+		}
+		return nil
+	}
+
+	words, err := newWordDiff(opts)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := printFileDiff(change.path, change.before, change.after, algorithm, words); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Détermine les deux côtés comparés (chemin -> version) à partir des options
+ */
+func diffSides(opts DiffOptions) (map[string]*fileVersion, map[string]*fileVersion, error) {
+	indexFiles, err := index.ReadFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.Staged {
+		if len(opts.Revs) > 1 {
+			return nil, nil, fmt.Errorf("--staged takes at most one revision")
+		}
+		rev := "HEAD"
+		if len(opts.Revs) == 1 {
+			rev = opts.Revs[0]
+		}
+		commitFiles, err := revisionFiles(rev)
+		if err != nil {
+			return nil, nil, err
+		}
+		return blobVersions(commitFiles), blobVersions(indexFiles), nil
+	}
+
+	switch len(opts.Revs) {
+	case 0:
+		return blobVersions(indexFiles), workingVersions(indexFiles), nil
+	case 1:
+		from, to, isRange, err := repository.ParseDiffRange(opts.Revs[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if isRange {
+			return commitVersions(from, to)
+		}
+		commitFiles, err := revisionFiles(opts.Revs[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		return blobVersions(commitFiles), workingVersions(indexFiles), nil
+	case 2:
+		from, err := repository.ResolveRevision(opts.Revs[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		to, err := repository.ResolveRevision(opts.Revs[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		return commitVersions(from, to)
+	default:
+		return nil, nil, fmt.Errorf("too many revisions")
+	}
+}
+
+/**
+ * Fichiers de l'arbre d'une révision (HEAD sans commit donne un arbre vide)
+ */
+func revisionFiles(rev string) (map[string]objects.FileEntry, error) {
+	if rev == "HEAD" {
+		return repository.HeadFiles()
+	}
+	commitHash, err := repository.ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CommitFiles(commitHash)
+}
+
+func commitVersions(fromHash, toHash string) (map[string]*fileVersion, map[string]*fileVersion, error) {
+	fromFiles, err := repository.CommitFiles(fromHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	toFiles, err := repository.CommitFiles(toHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return blobVersions(fromFiles), blobVersions(toFiles), nil
+}
+
+/**
+ * Versions stockées dans les objets (index ou arbre d'un commit)
+ */
+func blobVersions(files map[string]objects.FileEntry) map[string]*fileVersion {
+	versions := make(map[string]*fileVersion, len(files))
+	for path, entry := range files {
+		versions[path] = &fileVersion{entry: entry}
+	}
+	return versions
+}
+
+/**
+ * Versions du répertoire de travail des fichiers suivis (les fichiers supprimés sont absents)
+ */
+func workingVersions(tracked map[string]objects.FileEntry) map[string]*fileVersion {
+	versions := make(map[string]*fileVersion, len(tracked))
+	for path := range tracked {
+		if info, err := os.Lstat(path); err != nil || info.IsDir() {
+			continue
+		}
+		entry, content, err := objects.ReadWorkingFile(path)
+		if err != nil {
+			continue
+		}
+		// Même contenu qu'une entrée écrite par une ancienne version : pas de différence
+		if objects.IsLegacyMatch(tracked[path], entry, content) {
+			entry = tracked[path]
+		}
+		versions[path] = &fileVersion{entry: entry, content: content, loaded: true}
+	}
+	return versions
+}
+
+/**
+ * Affiche le diff unifié d'un fichier entre deux versions (nil : fichier absent)
+ * Avec words, les lignes modifiées de chaque bloc sont comparées mot à mot
+ */
+func printFileDiff(path string, before, after *fileVersion, algorithm diff.Algorithm, words *wordDiff) error {
+	fmt.Printf("%sdiff --goit a/%s b/%s%s\n", colorBold, path, path, colorReset)
+
+	oldName, newName := "a/"+path, "b/"+path
+	switch {
+	case before == nil:
+		fmt.Printf("%snew file mode %s%s\n", colorBold, after.entry.Mode, colorReset)
+		oldName = "/dev/null"
+	case after == nil:
+		fmt.Printf("%sdeleted file mode %s%s\n", colorBold, before.entry.Mode, colorReset)
+		newName = "/dev/null"
+	case before.entry.Mode != after.entry.Mode:
+		fmt.Printf("%sold mode %s%s\n", colorBold, before.entry.Mode, colorReset)
+		fmt.Printf("%snew mode %s%s\n", colorBold, after.entry.Mode, colorReset)
+	}
+
+	if before != nil && after != nil && before.entry.Hash == after.entry.Hash {
+		// Seul le mode a changé
+		return nil
+	}
+
+	var oldContent, newContent []byte
+	oldHash, newHash := strings.Repeat("0", 7), strings.Repeat("0", 7)
+	if before != nil {
+		content, err := before.read()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %v", path, err)
+		}
+		oldContent, oldHash = content, before.entry.Hash[:7]
+	}
+	if after != nil {
+		content, err := after.read()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %v", path, err)
+		}
+		newContent, newHash = content, after.entry.Hash[:7]
+	}
+
+	indexLine := fmt.Sprintf("index %s..%s", oldHash, newHash)
+	if before != nil && after != nil && before.entry.Mode == after.entry.Mode {
+		indexLine += " " + before.entry.Mode
+	}
+	fmt.Printf("%s%s%s\n", colorBold, indexLine, colorReset)
+	if isBinary(path, oldContent) || isBinary(path, newContent) {
+		fmt.Printf("Binary files %s and %s differ\n", oldName, newName)
+		return nil
+	}
+	fmt.Printf("%s--- %s%s\n", colorBold, oldName, colorReset)
+	fmt.Printf("%s+++ %s%s\n", colorBold, newName, colorReset)
+
+	edits := algorithm.Diff(diff.SplitLines(string(oldContent)), diff.SplitLines(string(newContent)))
+	for _, hunk := range diff.Hunks(edits, diff.DefaultContext) {
+		if words != nil {
+			words.printHunk(hunk, algorithm)
+			continue
+		}
+		fmt.Printf("%s%s%s\n", colorCyan, hunk.Header(), colorReset)
+		for _, edit := range hunk.Edits {
+			printDiffLine(edit)
+		}
+	}
+	return nil
+}
+
+/**
+ * Affiche une ligne d'un bloc : "-" en rouge, "+" en vert, contexte sans couleur
+ */
+func printDiffLine(edit diff.Edit) {
+	line := strings.TrimSuffix(edit.Line, "\n")
+	switch edit.Op {
+	case diff.Delete:
+		fmt.Printf("%s-%s%s\n", colorRed, line, colorReset)
+	case diff.Insert:
+		fmt.Printf("%s+%s%s\n", colorGreen, line, colorReset)
+	default:
+		fmt.Printf(" %s\n", line)
+	}
+	if !strings.HasSuffix(edit.Line, "\n") {
+		fmt.Println("\\ No newline at end of file")
+	}
+}
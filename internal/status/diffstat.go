@@ -0,0 +1,164 @@
+package status
+
+import (
+	"fmt"
+	"projet-go-git/internal/diff"
+	"strconv"
+	"strings"
+)
+
+// Largeur maximale d'une ligne de --stat
+const statWidth = 80
+
+/**
+ * Statistiques d'un fichier modifié
+ * Pour un fichier binaire, seules les tailles des deux versions sont connues
+ */
+type fileStat struct {
+	path       string
+	insertions int
+	deletions  int
+	binary     bool
+	oldSize    int
+	newSize    int
+}
+
+/**
+ * Calcule les lignes ajoutées et supprimées de chaque changement
+ */
+func computeStats(changes []fileChange, algorithm diff.Algorithm) ([]fileStat, error) {
+	stats := make([]fileStat, 0, len(changes))
+	for _, change := range changes {
+		var oldContent, newContent []byte
+		if change.before != nil {
+			content, err := change.before.read()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %v", change.path, err)
+			}
+			oldContent = content
+		}
+		if change.after != nil {
+			content, err := change.after.read()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %v", change.path, err)
+			}
+			newContent = content
+		}
+
+		stat := fileStat{path: change.path, oldSize: len(oldContent), newSize: len(newContent)}
+		if isBinary(change.path, oldContent) || isBinary(change.path, newContent) {
+			stat.binary = true
+		} else {
+			edits := algorithm.Diff(diff.SplitLines(string(oldContent)), diff.SplitLines(string(newContent)))
+			for _, edit := range edits {
+				switch edit.Op {
+				case diff.Insert:
+					stat.insertions++
+				case diff.Delete:
+					stat.deletions++
+				}
+			}
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+/**
+ * Affiche "<ajouts>\t<suppressions>\t<chemin>" par fichier ("-" pour un fichier binaire)
+ */
+func printNumstat(stats []fileStat) {
+	for _, stat := range stats {
+		if stat.binary {
+			fmt.Printf("-\t-\t%s\n", stat.path)
+		} else {
+			fmt.Printf("%d\t%d\t%s\n", stat.insertions, stat.deletions, stat.path)
+		}
+	}
+}
+
+/**
+ * Affiche la ligne de résumé : nombre de fichiers, d'ajouts et de suppressions
+ */
+func printShortstat(stats []fileStat) {
+	if len(stats) == 0 {
+		return
+	}
+	insertions, deletions := 0, 0
+	for _, stat := range stats {
+		insertions += stat.insertions
+		deletions += stat.deletions
+	}
+
+	summary := fmt.Sprintf(" %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if insertions > 0 || deletions == 0 {
+		summary += fmt.Sprintf(", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+	if deletions > 0 || insertions == 0 {
+		summary += fmt.Sprintf(", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+	fmt.Println(summary)
+}
+
+func plural(count int, singular, pluralForm string) string {
+	if count == 1 {
+		return singular
+	}
+	return pluralForm
+}
+
+/**
+ * Affiche chaque fichier avec son nombre de lignes modifiées et un histogramme de "+" et "-",
+ * réduit proportionnellement si les lignes dépassent statWidth, puis le résumé
+ */
+func printStat(stats []fileStat) {
+	if len(stats) == 0 {
+		return
+	}
+
+	nameWidth, countWidth, maxChanges := 0, 0, 0
+	for _, stat := range stats {
+		nameWidth = max(nameWidth, len(stat.path))
+		count := "Bin"
+		if !stat.binary {
+			count = strconv.Itoa(stat.insertions + stat.deletions)
+			maxChanges = max(maxChanges, stat.insertions+stat.deletions)
+		}
+		countWidth = max(countWidth, len(count))
+	}
+
+	// " <nom> | <nombre> <histogramme>"
+	graphWidth := max(statWidth-nameWidth-countWidth-5, 10)
+
+	for _, stat := range stats {
+		if stat.binary {
+			fmt.Printf(" %-*s | %*s %d -> %d bytes\n", nameWidth, stat.path, countWidth, "Bin", stat.oldSize, stat.newSize)
+			continue
+		}
+
+		plus, minus := stat.insertions, stat.deletions
+		if maxChanges > graphWidth {
+			plus = scaleStat(stat.insertions, maxChanges, graphWidth)
+			minus = scaleStat(stat.deletions, maxChanges, graphWidth)
+		}
+		graph := ""
+		if plus > 0 {
+			graph += colorGreen + strings.Repeat("+", plus) + colorReset
+		}
+		if minus > 0 {
+			graph += colorRed + strings.Repeat("-", minus) + colorReset
+		}
+		fmt.Printf(" %-*s | %*d %s\n", nameWidth, stat.path, countWidth, stat.insertions+stat.deletions, graph)
+	}
+	printShortstat(stats)
+}
+
+/**
+ * Réduit un nombre de lignes à la largeur de l'histogramme (au moins un caractère s'il est non nul)
+ */
+func scaleStat(count, maxChanges, width int) int {
+	if count == 0 {
+		return 0
+	}
+	return max(1, count*width/maxChanges)
+}
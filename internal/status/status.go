@@ -1,39 +1,24 @@
 package status
 
 import (
-	"crypto/sha1"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"projet-go-git/internal/index"
+	"projet-go-git/internal/objects"
 	"projet-go-git/internal/repository"
+	"sort"
 	"strings"
 )
 
 const (
 	colorRed   = "\033[31m"
 	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+	colorBold  = "\033[1m"
 	colorReset = "\033[0m"
 )
 
-/**
- * Calcule le hash SHA1 d'un fichier
- */
-func hashFile(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	h := sha1.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
-	}
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
-}
-
 /**
  * Récupère le nom de la branche actuelle
  */
@@ -91,307 +76,128 @@ func shouldIgnoreFile(path string) bool {
 	return false
 }
 
-/**
- * Vérifie si un fichier contient des marqueurs de conflit
- */
-func hasConflictMarkers(filename string) bool {
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return false
-	}
-
-	contentStr := string(content)
-	return strings.Contains(contentStr, "**************") &&
-		strings.Contains(contentStr, "=========")
-}
-
-/**
- * Vérifie si un fichier a changé par rapport à sa version indexée
- */
-func hasFileChanged(filename, indexHash string) bool {
-	currentHash, err := hashFile(filename)
-	if err != nil {
-		return true // Fichier supprimé ou inaccessible
-	}
-
-	return currentHash != indexHash
-}
-
 /**
  * Vérifie si un fichier est suivi par goit
+ * L'index contient l'instantané complet des fichiers suivis
  */
-func isTracked(filename string, indexEntries map[string]string, commitEntries map[string]string) bool {
+func isTracked(filename string, indexEntries map[string]objects.FileEntry) bool {
 	_, existsInIndex := indexEntries[filename]
-	_, existsInCommit := commitEntries[filename]
-	return existsInIndex || existsInCommit
+	return existsInIndex
 }
 
 /**
- * Récupère les fichiers du dernier commit avec leurs hashes
+ * Charge l'index directement depuis le fichier
  */
-func getLastCommitFiles() map[string]string {
-	commitFiles := make(map[string]string)
-
-	head, err := os.ReadFile(".goit/HEAD")
+func loadIndexDirect() map[string]objects.FileEntry {
+	indexEntries, err := index.ReadFiles()
 	if err != nil {
-		return commitFiles
+		return make(map[string]objects.FileEntry)
 	}
-
-	headContent := strings.TrimSpace(string(head))
-	var commitHash string
-
-	if strings.HasPrefix(headContent, "ref: ") {
-		refPath := strings.TrimPrefix(headContent, "ref: ")
-		refFile := filepath.Join(".goit", refPath)
-		if content, err := os.ReadFile(refFile); err == nil {
-			commitHash = strings.TrimSpace(string(content))
-		}
-	} else {
-		commitHash = headContent
-	}
-
-	if commitHash == "" {
-		return commitFiles
-	}
-
-	commitPath := filepath.Join(".goit", "objects", commitHash)
-	commitData, err := os.ReadFile(commitPath)
-	if err != nil {
-		return commitFiles
-	}
-
-	lines := strings.Split(string(commitData), "\n")
-	var treeHash string
-	for _, line := range lines {
-		if strings.HasPrefix(line, " tree ") {
-			treeHash = strings.TrimPrefix(line, " tree ")
-			break
-		}
-	}
-
-	if treeHash == "" {
-		return commitFiles
-	}
-
-	treePath := filepath.Join(".goit", "objects", treeHash)
-	treeData, err := os.ReadFile(treePath)
-	if err != nil {
-		return commitFiles
-	}
-
-	treeLines := strings.Split(string(treeData), "\n")
-	for _, line := range treeLines {
-		if line != "" && !strings.HasPrefix(line, "tree") {
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) == 2 {
-				hash, filename := parts[0], parts[1]
-				commitFiles[filename] = hash
-			}
-		}
-	}
-
-	return commitFiles
+	return indexEntries
 }
 
 /**
- * Charge l'index directement depuis le fichier
+ * Décrit un chemin en conflit à partir des versions présentes dans l'index
  */
-func loadIndexDirect() map[string]string {
-	indexEntries := make(map[string]string)
-	indexContent, err := os.ReadFile(".goit/index")
-	if err != nil {
-		return indexEntries
-	}
-
-	entries := strings.Split(string(indexContent), "\n")
-	for _, entry := range entries {
-		if entry == "" {
-			continue
-		}
-		parts := strings.SplitN(entry, " ", 2)
-		if len(parts) == 2 {
-			hash, filename := parts[0], parts[1]
-			indexEntries[filename] = hash
-		}
+func conflictLabel(conflict index.Conflict) string {
+	switch {
+	case conflict.Ours != nil && conflict.Theirs != nil && conflict.Base == nil:
+		return "both added:"
+	case conflict.Ours != nil && conflict.Theirs != nil:
+		return "both modified:"
+	case conflict.Ours == nil && conflict.Base != nil:
+		return "deleted by us:"
+	case conflict.Theirs == nil && conflict.Base != nil:
+		return "deleted by them:"
+	case conflict.Ours != nil:
+		return "added by us:"
+	default:
+		return "added by them:"
 	}
-	return indexEntries
 }
 
 /**
  * Afficher les fichiers avec conflits pendant un merge
+ * Les chemins non résolus sont ceux qui ont encore des étapes de conflit dans l'index
  */
-func showMergeConflicts() {
-	var conflictFiles []string
-	var resolvedFiles []string
-
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			if shouldIgnoreDirectory(path) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		if shouldIgnoreFile(path) {
-			return nil
-		}
-
-		// Vérifier si le fichier contient des marqueurs de conflit
-		if hasConflictMarkers(path) {
-			conflictFiles = append(conflictFiles, path)
-		} else {
-			// Vérifier si le fichier a été résolu (ajouté à l'index)
-			indexEntries := loadIndexDirect()
-			if _, exists := indexEntries[path]; exists {
-				resolvedFiles = append(resolvedFiles, path)
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		fmt.Printf("Error checking for conflicts: %v\n", err)
+func showMergeConflicts(conflicts map[string]index.Conflict) {
+	if len(conflicts) == 0 {
+		fmt.Println("All conflicts fixed but you are still merging.")
+		fmt.Println("  (use \"goit resolve\" to conclude merge)")
+		fmt.Println()
 		return
 	}
 
-	if len(conflictFiles) > 0 {
-		fmt.Println("Unmerged paths:")
-		for _, file := range conflictFiles {
-			fmt.Printf("  %sboth modified:   %s%s\n", colorRed, file, colorReset)
-		}
-		fmt.Println()
+	var paths []string
+	for path := range conflicts {
+		paths = append(paths, path)
 	}
+	sort.Strings(paths)
 
-	if len(resolvedFiles) > 0 {
-		fmt.Println("All conflicts fixed but you are still merging:")
-		for _, file := range resolvedFiles {
-			fmt.Printf("  %smodified:   %s%s\n", colorGreen, file, colorReset)
-		}
-		fmt.Println()
-		fmt.Println("Use 'goit resolve' to complete the merge")
+	fmt.Println("Unmerged paths:")
+	for _, path := range paths {
+		fmt.Printf("  %s%-17s%s%s\n", colorRed, conflictLabel(conflicts[path]), path, colorReset)
 	}
+	fmt.Println()
 }
 
 /**
- * Affiche les différences entre les fichiers staged et working
+ * Affiche le statut du repository
  */
-func ShowDiff(filename string) {
-	if filename == "" {
-		showAllDiffs()
-		return
-	}
-	showFileDiff(filename)
-}
-
-func showAllDiffs() {
-	indexEntries := loadIndexDirect()
-	if len(indexEntries) == 0 {
-		fmt.Println("No staged files to diff")
-		return
-	}
-
-	hasChanges := false
-	for filename, indexHash := range indexEntries {
-		if hasFileChanged(filename, indexHash) {
-			if !hasChanges {
-				fmt.Println("Differences found:")
-				hasChanges = true
-			}
-			fmt.Printf("\ndiff --goit a/%s b/%s\n", filename, filename)
-			showFileDiff(filename)
+func ShowStatus() {
+	location := "On branch " + getCurrentBranchName()
+	if repository.IsDetached() {
+		if hash, err := repository.GetCurrentCommitHash(); err == nil && len(hash) >= 8 {
+			location = "HEAD detached at " + hash[:8]
 		}
 	}
 
-	if !hasChanges {
-		fmt.Println("No differences found")
-	}
-}
-
-func showFileDiff(filename string) {
-	fmt.Printf("File: %s\n", filename)
-
-	indexEntries := loadIndexDirect()
-	indexHash, exists := indexEntries[filename]
-	if !exists {
-		fmt.Println("File not staged")
-		return
-	}
-
-	objectPath := filepath.Join(".goit", "objects", indexHash)
-	stagedContent, err := os.ReadFile(objectPath)
+	conflicts, err := index.ReadConflicts()
 	if err != nil {
-		fmt.Printf("Cannot read staged version: %v\n", err)
-		return
+		conflicts = make(map[string]index.Conflict)
 	}
 
-	workingContent, err := os.ReadFile(filename)
-	if err != nil {
-		fmt.Println("Working file deleted or inaccessible")
-		return
-	}
-
-	if string(stagedContent) != string(workingContent) {
-		fmt.Println("--- staged version")
-		fmt.Println("+++ working version")
-		fmt.Println("Files differ (detailed line-by-line diff not implemented)")
-
-		fmt.Printf("Staged version: %d bytes\n", len(stagedContent))
-		fmt.Printf("Working version: %d bytes\n", len(workingContent))
-	} else {
-		fmt.Println("No differences")
-	}
-}
-
-/**
- * Affiche le statut du repository
- */
-func ShowStatus() {
-	currentBranch := getCurrentBranchName()
-
 	// Afficher le statut de merge
-	if isMergeInProgress() {
-		fmt.Printf("On branch %s\n", currentBranch)
+	if len(conflicts) > 0 {
+		fmt.Printf("%s\n", location)
 		fmt.Printf("You have unmerged paths.\n")
 		fmt.Printf("  (fix conflicts and run \"goit resolve\")\n")
 		fmt.Printf("  (use \"goit add <file>...\" to mark resolution)\n\n")
 	} else {
-		fmt.Printf("On branch %s\n\n", currentBranch)
+		fmt.Printf("%s\n\n", location)
 	}
 
-	entries, err := index.GetIndexEntries()
-	var indexEntries map[string]string
+	indexEntries := loadIndexDirect()
 
+	commitEntries, err := repository.HeadFiles()
 	if err != nil {
-		indexEntries = loadIndexDirect()
-	} else {
-		indexEntries = make(map[string]string)
-		for _, entry := range entries {
-			indexEntries[entry.Filename] = entry.Hash
-		}
+		commitEntries = make(map[string]objects.FileEntry)
 	}
 
-	commitEntries := getLastCommitFiles()
-
 	// Améliorer la détection des fichiers à commiter
 	var stagedModified []string
 	var stagedNew []string
+	var stagedDeleted []string
 
-	for filename, indexHash := range indexEntries {
-		commitHash, existsInCommit := commitEntries[filename]
+	for filename, indexEntry := range indexEntries {
+		commitEntry, existsInCommit := commitEntries[filename]
 		if !existsInCommit {
 			stagedNew = append(stagedNew, filename)
-		} else if commitHash != indexHash {
+		} else if commitEntry != indexEntry {
 			stagedModified = append(stagedModified, filename)
 		}
 	}
 
+	for filename := range commitEntries {
+		_, existsInIndex := indexEntries[filename]
+		_, conflicted := conflicts[filename]
+		if !existsInIndex && !conflicted {
+			stagedDeleted = append(stagedDeleted, filename)
+		}
+	}
+
 	// Afficher les fichiers stagés
-	if len(stagedNew) > 0 || len(stagedModified) > 0 {
+	if len(stagedNew) > 0 || len(stagedModified) > 0 || len(stagedDeleted) > 0 {
 		fmt.Println("Changes to be committed:")
 		for _, filename := range stagedNew {
 			fmt.Printf("  %snew file:   %s%s\n", colorGreen, filename, colorReset)
@@ -399,17 +205,21 @@ func ShowStatus() {
 		for _, filename := range stagedModified {
 			fmt.Printf("  %smodified:   %s%s\n", colorGreen, filename, colorReset)
 		}
+		for _, filename := range stagedDeleted {
+			fmt.Printf("  %sdeleted:    %s%s\n", colorGreen, filename, colorReset)
+		}
 		fmt.Println()
 	}
 
 	// Pendant un merge, afficher les fichiers avec conflits
-	if isMergeInProgress() {
-		showMergeConflicts()
-		return
+	if isMergeInProgress() || len(conflicts) > 0 {
+		showMergeConflicts(conflicts)
 	}
 
 	var modified []string
+	var deleted []string
 	var untracked []string
+	legacyEntries := 0
 
 	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -429,21 +239,19 @@ func ShowStatus() {
 
 		relPath := strings.TrimPrefix(path, "./")
 
-		if isTracked(relPath, indexEntries, commitEntries) {
-			currentHash, err := hashFile(path)
+		if isTracked(relPath, indexEntries) {
+			currentEntry, content, err := objects.ReadWorkingFile(path)
 			if err != nil {
 				return nil // Ignorer les erreurs de lecture
 			}
 
-			expectedHash := indexEntries[relPath]
-			if expectedHash == "" {
-				expectedHash = commitEntries[relPath]
-			}
-
-			if expectedHash != "" && currentHash != expectedHash {
+			// Un index écrit par une ancienne version contient le hash du contenu sans en-tête
+			if objects.IsLegacyMatch(indexEntries[relPath], currentEntry, content) {
+				legacyEntries++
+			} else if currentEntry != indexEntries[relPath] {
 				modified = append(modified, relPath)
 			}
-		} else {
+		} else if _, conflicted := conflicts[relPath]; !conflicted {
 			untracked = append(untracked, relPath)
 		}
 
@@ -455,11 +263,21 @@ func ShowStatus() {
 		return
 	}
 
-	if len(modified) > 0 {
+	// Fichiers suivis supprimés du répertoire de travail
+	for filename := range indexEntries {
+		if _, err := os.Lstat(filename); os.IsNotExist(err) {
+			deleted = append(deleted, filename)
+		}
+	}
+
+	if len(modified) > 0 || len(deleted) > 0 {
 		fmt.Println("Changes not staged for commit:")
 		for _, file := range modified {
 			fmt.Printf("  %smodified:   %s%s\n", colorRed, file, colorReset)
 		}
+		for _, file := range deleted {
+			fmt.Printf("  %sdeleted:    %s%s\n", colorRed, file, colorReset)
+		}
 		fmt.Println()
 	}
 
@@ -472,7 +290,20 @@ func ShowStatus() {
 		fmt.Println("Use 'goit add <file>' to include in what will be committed")
 	}
 
-	if len(stagedNew) == 0 && len(stagedModified) == 0 && len(modified) == 0 && len(untracked) == 0 {
+	if repository.RequireFullSnapshots() != nil {
+		fmt.Println("The commits of this repository only contain the files staged by an older goit version.")
+		fmt.Println("Run 'goit migrate snapshots' to rebuild them before committing.")
+		fmt.Println()
+	}
+
+	if legacyEntries > 0 {
+		fmt.Printf("%d file(s) in the index use the object format of an older goit version.\n", legacyEntries)
+		fmt.Println("Run 'goit migrate objects' to convert them.")
+		fmt.Println()
+	}
+
+	if len(stagedNew) == 0 && len(stagedModified) == 0 && len(stagedDeleted) == 0 &&
+		len(modified) == 0 && len(deleted) == 0 && len(untracked) == 0 && len(conflicts) == 0 {
 		fmt.Println("nothing to commit, working tree clean")
 	}
 }
@@ -0,0 +1,166 @@
+package status
+
+import (
+	"fmt"
+	"projet-go-git/internal/config"
+	"projet-go-git/internal/diff"
+	"regexp"
+	"strings"
+)
+
+/**
+ * Modes de --word-diff
+ * - WordDiffPlain : [-supprimé-]{+ajouté+}
+ * - WordDiffColor : mots supprimés en rouge et ajoutés en vert, sans marqueurs
+ * - WordDiffPorcelain : un morceau par ligne préfixé par " ", "-" ou "+", "~" pour un retour à la ligne
+ */
+const (
+	WordDiffPlain     = "plain"
+	WordDiffColor     = "color"
+	WordDiffPorcelain = "porcelain"
+)
+
+/**
+ * Réglages d'un diff par mots
+ */
+type wordDiff struct {
+	mode  string
+	regex *regexp.Regexp
+}
+
+/**
+ * Prépare le diff par mots demandé par opts (nil pour un diff par lignes)
+ * L'expression des mots vient de opts.WordRegex, sinon de diff.wordRegex dans la configuration
+ */
+func newWordDiff(opts DiffOptions) (*wordDiff, error) {
+	if opts.WordDiff == "" {
+		return nil, nil
+	}
+	switch opts.WordDiff {
+	case WordDiffPlain, WordDiffColor, WordDiffPorcelain:
+	default:
+		return nil, fmt.Errorf("bad --word-diff argument: %s", opts.WordDiff)
+	}
+
+	pattern := opts.WordRegex
+	if pattern == "" {
+		pattern, _ = config.Get("diff.wordRegex")
+	}
+	regex := diff.DefaultWordRegex
+	if pattern != "" {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid word regex '%s': %v", pattern, err)
+		}
+		regex = compiled
+	}
+	return &wordDiff{mode: opts.WordDiff, regex: regex}, nil
+}
+
+/**
+ * Affiche un bloc mot à mot
+ */
+func (w *wordDiff) printHunk(hunk diff.Hunk, algorithm diff.Algorithm) {
+	segments := w.hunkSegments(hunk, algorithm)
+	fmt.Printf("%s%s%s\n", colorCyan, hunk.Header(), colorReset)
+	if w.mode == WordDiffPorcelain {
+		fmt.Print(renderPorcelainWords(segments))
+	} else {
+		fmt.Print(w.renderInlineWords(segments))
+	}
+}
+
+/**
+ * Morceaux d'un bloc : les lignes de contexte telles quelles, chaque suite de lignes
+ * supprimées et ajoutées comparée par mots
+ */
+func (w *wordDiff) hunkSegments(hunk diff.Hunk, algorithm diff.Algorithm) []diff.WordSegment {
+	var segments []diff.WordSegment
+	var removed, added strings.Builder
+	flush := func() {
+		if removed.Len() > 0 || added.Len() > 0 {
+			segments = append(segments, diff.Words(removed.String(), added.String(), w.regex, algorithm)...)
+			removed.Reset()
+			added.Reset()
+		}
+	}
+
+	for _, edit := range hunk.Edits {
+		switch edit.Op {
+		case diff.Delete:
+			removed.WriteString(edit.Line)
+		case diff.Insert:
+			added.WriteString(edit.Line)
+		default:
+			flush()
+			segments = append(segments, diff.WordSegment{Op: diff.Equal, Text: edit.Line})
+		}
+	}
+	flush()
+	return segments
+}
+
+/**
+ * Texte de la nouvelle version avec les modifications marquées (plain) ou colorées (color) ligne par ligne
+ */
+func (w *wordDiff) renderInlineWords(segments []diff.WordSegment) string {
+	var out strings.Builder
+	for _, segment := range segments {
+		if segment.Op == diff.Equal {
+			out.WriteString(segment.Text)
+			continue
+		}
+
+		prefix, suffix := "[-", "-]"
+		if segment.Op == diff.Insert {
+			prefix, suffix = "{+", "+}"
+		}
+		if w.mode == WordDiffColor {
+			prefix, suffix = colorRed, colorReset
+			if segment.Op == diff.Insert {
+				prefix = colorGreen
+			}
+		}
+
+		// Les marqueurs sont fermés à chaque fin de ligne
+		for i, part := range strings.Split(segment.Text, "\n") {
+			if i > 0 {
+				out.WriteString("\n")
+			}
+			if part != "" {
+				out.WriteString(prefix + part + suffix)
+			}
+		}
+	}
+
+	text := out.String()
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text
+}
+
+/**
+ * Format pour les scripts : un morceau par ligne, "~" marque la fin d'une ligne du fichier
+ */
+func renderPorcelainWords(segments []diff.WordSegment) string {
+	var out strings.Builder
+	for _, segment := range segments {
+		prefix := " "
+		switch segment.Op {
+		case diff.Delete:
+			prefix = "-"
+		case diff.Insert:
+			prefix = "+"
+		}
+		for i, part := range strings.Split(segment.Text, "\n") {
+			if i > 0 {
+				out.WriteString("~\n")
+			}
+			if part != "" {
+				out.WriteString(prefix + part + "\n")
+			}
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,109 @@
+package status
+
+import (
+	"projet-go-git/internal/diff"
+	"testing"
+)
+
+/**
+ * Rendu mot à mot du premier bloc du diff entre deux contenus
+ */
+func renderWords(t *testing.T, mode, oldContent, newContent string) string {
+	t.Helper()
+	w, err := newWordDiff(DiffOptions{WordDiff: mode, WordRegex: `\S+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits := diff.Lines(diff.SplitLines(oldContent), diff.SplitLines(newContent))
+	hunks := diff.Hunks(edits, diff.DefaultContext)
+	if len(hunks) != 1 {
+		t.Fatalf("expected one hunk, got %d", len(hunks))
+	}
+	segments := w.hunkSegments(hunks[0], diff.Myers)
+	if mode == WordDiffPorcelain {
+		return renderPorcelainWords(segments)
+	}
+	return w.renderInlineWords(segments)
+}
+
+func TestWordDiffModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		oldContent string
+		newContent string
+		want       string
+	}{
+		{
+			name:       "deletion only, plain",
+			mode:       WordDiffPlain,
+			oldContent: "one\ntwo\nthree\nfour",
+			newContent: "one\nfour",
+			want:       "one\n[-two-]\n[-three-]\nfour\n",
+		},
+		{
+			name:       "deletion only, color",
+			mode:       WordDiffColor,
+			oldContent: "one\ntwo\nthree\nfour",
+			newContent: "one\nfour",
+			want:       "one\n" + colorRed + "two" + colorReset + "\n" + colorRed + "three" + colorReset + "\nfour\n",
+		},
+		{
+			name:       "deletion only, porcelain",
+			mode:       WordDiffPorcelain,
+			oldContent: "one\ntwo\nthree\nfour",
+			newContent: "one\nfour",
+			want:       " one\n~\n-two\n~\n-three\n~\n four\n",
+		},
+		{
+			name:       "insertion only, plain",
+			mode:       WordDiffPlain,
+			oldContent: "one\nfour\n",
+			newContent: "one\ntwo\nthree\nfour\n",
+			want:       "one\n{+two+}\n{+three+}\nfour\n",
+		},
+		{
+			name:       "insertion only, color",
+			mode:       WordDiffColor,
+			oldContent: "one\nfour\n",
+			newContent: "one\ntwo\nthree\nfour\n",
+			want:       "one\n" + colorGreen + "two" + colorReset + "\n" + colorGreen + "three" + colorReset + "\nfour\n",
+		},
+		{
+			name:       "insertion only, porcelain",
+			mode:       WordDiffPorcelain,
+			oldContent: "one\nfour\n",
+			newContent: "one\ntwo\nthree\nfour\n",
+			want:       " one\n~\n+two\n~\n+three\n~\n four\n~\n",
+		},
+		{
+			name:       "deleted word inside a line keeps the line",
+			mode:       WordDiffPlain,
+			oldContent: "a b c\n",
+			newContent: "a c\n",
+			want:       "a[-b-] c\n",
+		},
+		{
+			name:       "replaced word",
+			mode:       WordDiffPorcelain,
+			oldContent: "foo bar baz\n",
+			newContent: "foo qux baz\n",
+			want:       " foo \n-bar\n+qux\n  baz\n~\n",
+		},
+		{
+			name:       "deleted last word of a rewritten line",
+			mode:       WordDiffPlain,
+			oldContent: "x y\nz\n",
+			newContent: "x\nz\n",
+			want:       "x[-y-]\nz\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderWords(t, tt.mode, tt.oldContent, tt.newContent); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
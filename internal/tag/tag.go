@@ -0,0 +1,62 @@
+package tag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"projet-go-git/internal/repository"
+)
+
+/**
+ * Crée un tag léger pointant vers un commit (HEAD par défaut)
+ */
+func Create(name, rev string) {
+	if err := repository.CheckRefName(name); err != nil {
+		fmt.Printf("Invalid tag name: %s (%v)\n", name, err)
+		return
+	}
+
+	tagsDir := filepath.Join(".goit", "refs", "tags")
+	tagPath := filepath.Join(tagsDir, name)
+
+	if _, err := os.Stat(tagPath); err == nil {
+		fmt.Printf("Tag '%s' already exists\n", name)
+		return
+	}
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+	commitHash, err := repository.ResolveRevision(rev)
+	if err != nil {
+		fmt.Printf("Cannot create tag: %v\n", err)
+		return
+	}
+
+	if err := os.MkdirAll(tagsDir, 0755); err != nil {
+		fmt.Printf("Failed to create tag: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(tagPath, []byte(commitHash), 0644); err != nil {
+		fmt.Printf("Failed to create tag: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Tag '%s' created at %s\n", name, commitHash[:8])
+}
+
+func List() {
+	entries, err := os.ReadDir(filepath.Join(".goit", "refs", "tags"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("Cannot list tags: %v\n", err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			fmt.Println(entry.Name())
+		}
+	}
+}